@@ -0,0 +1,415 @@
+package ged
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Node is implemented by every statement and expression.
+type Node interface {
+	String() string
+	Span() Span
+	Source(input string) string
+}
+
+// Expr is a node that produces a value. String renders the node fully
+// parenthesized, which makes the tree shape visible when debugging.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Statement is a top-level unit of a program, terminated by a semicolon.
+type Statement interface {
+	Node
+	statementNode()
+}
+
+// Span is the byte range a node was parsed from, End being just past its
+// last byte. A statement's span includes its semicolon; an expression's
+// leaves out any parentheses around it.
+type Span struct {
+	Start, End int
+}
+
+// nodeBase is embedded in every node to record its span. Nodes built other
+// than by the parser have an empty span.
+type nodeBase struct {
+	span Span
+}
+
+func (b *nodeBase) Span() Span {
+	return b.span
+}
+
+// Source returns the text of the node in input, which must be the source it
+// was parsed from.
+func (b *nodeBase) Source(input string) string {
+	if b.span.Start < 0 || b.span.Start > b.span.End || b.span.End > len(input) {
+		return ""
+	}
+	return input[b.span.Start:b.span.End]
+}
+
+// Program is a parsed source file.
+type Program struct {
+	Statements []Statement
+}
+
+func (p *Program) String() string {
+	lines := make([]string, len(p.Statements))
+	for i, st := range p.Statements {
+		lines[i] = st.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+type LetStatement struct {
+	nodeBase
+	Token Token
+	Name  string
+	Value Expr
+}
+
+func (s *LetStatement) statementNode() {}
+
+func (s *LetStatement) String() string {
+	return fmt.Sprintf("let %s = %s;", s.Name, s.Value)
+}
+
+// FunctionDef is a let binding with at least one parameter, such as
+// `let add a b = a + b;`.
+type FunctionDef struct {
+	nodeBase
+	Token  Token
+	Name   string
+	Params []string
+	Body   Expr
+}
+
+func (s *FunctionDef) statementNode() {}
+
+func (s *FunctionDef) String() string {
+	return fmt.Sprintf("let %s %s = %s;", s.Name, strings.Join(s.Params, " "), s.Body)
+}
+
+// AssignStatement updates an existing binding, such as `x = x + 1;`.
+// Token is the name being assigned.
+type AssignStatement struct {
+	nodeBase
+	Token Token
+	Name  string
+	Value Expr
+}
+
+func (s *AssignStatement) statementNode() {}
+
+func (s *AssignStatement) String() string {
+	return fmt.Sprintf("%s = %s;", s.Name, s.Value)
+}
+
+// ExpressionStatement evaluates Expr for its effects, such as `f x;`. The
+// value is discarded, except that the REPL prints it.
+type ExpressionStatement struct {
+	nodeBase
+	Expr Expr
+}
+
+func (s *ExpressionStatement) statementNode() {}
+
+func (s *ExpressionStatement) String() string {
+	return s.Expr.String() + ";"
+}
+
+type NumberLiteral struct {
+	nodeBase
+	Token Token
+}
+
+type StringLiteral struct {
+	nodeBase
+	Token Token
+}
+
+// CharacterLiteral is a single character such as 'a' or '\n', held
+// decoded in Token.Value. It evaluates to a string of that one character,
+// as there is no separate character type.
+type CharacterLiteral struct {
+	nodeBase
+	Token Token
+}
+
+// InterpStringExpr is a string with embedded ${...} expressions. Parts
+// alternates between literal text, held as StringLiterals, and the
+// embedded expressions, starting and ending with literal text.
+type InterpStringExpr struct {
+	nodeBase
+	Token Token
+	Parts []Expr
+}
+
+// RecordLiteral is a record such as { x: 1, y: 2 }, with Keys[i] naming
+// Values[i].
+type RecordLiteral struct {
+	nodeBase
+	Token  Token
+	Keys   []string
+	Values []Expr
+}
+
+// LetInExpr binds Name to Value only while evaluating Body, and takes the
+// value of Body: let x = 5 in x + 1.
+type LetInExpr struct {
+	nodeBase
+	Token Token
+	Name  string
+	Value Expr
+	Body  Expr
+}
+
+// WhereExpr evaluates Expr with the definitions that follow it bound, as
+// in x * y where x = 2, y = x + 1. Names[i] is bound to Values[i] in order,
+// so a value can use the names defined before it but not those after.
+// Token is the where keyword.
+type WhereExpr struct {
+	nodeBase
+	Token  Token
+	Expr   Expr
+	Names  []string
+	Values []Expr
+}
+
+// ListExpr is a list literal such as [1, 2, 3].
+type ListExpr struct {
+	nodeBase
+	Token    Token
+	Elements []Expr
+}
+
+// IndexExpr reads the element at Index of Object, as in xs[0]. Token is the
+// opening bracket.
+type IndexExpr struct {
+	nodeBase
+	Token  Token
+	Object Expr
+	Index  Expr
+}
+
+// BlockExpr runs Statements in a scope of their own and takes the value of
+// Result, or no value when Result is nil: { let y = x * 2; y + 1 }.
+type BlockExpr struct {
+	nodeBase
+	Token      Token
+	Statements []Statement
+	Result     Expr
+}
+
+// MemberExpr reads the field Name of Object, as in point.x. Token is the
+// dot.
+type MemberExpr struct {
+	nodeBase
+	Token  Token
+	Object Expr
+	Name   string
+}
+
+type BoolLiteral struct {
+	nodeBase
+	Token Token
+}
+
+type Ident struct {
+	nodeBase
+	Token Token
+	Name  string
+}
+
+type UnaryExpr struct {
+	nodeBase
+	Op      Token
+	Operand Expr
+}
+
+type BinaryExpr struct {
+	nodeBase
+	Op    Token
+	Left  Expr
+	Right Expr
+}
+
+// CallExpr applies Callee to Args. Juxtaposed arguments are collected into a
+// single call, so `f a b` holds both a and b.
+type CallExpr struct {
+	nodeBase
+	Token  Token
+	Callee Expr
+	Args   []Expr
+}
+
+// IfExpr is `if Cond then Then else Else`; both branches are required.
+type IfExpr struct {
+	nodeBase
+	Token Token
+	Cond  Expr
+	Then  Expr
+	Else  Expr
+}
+
+func (n *NumberLiteral) exprNode()    {}
+func (n *StringLiteral) exprNode()    {}
+func (n *CharacterLiteral) exprNode() {}
+func (n *InterpStringExpr) exprNode() {}
+func (n *RecordLiteral) exprNode()    {}
+func (n *MemberExpr) exprNode()       {}
+func (n *ListExpr) exprNode()         {}
+func (n *LetInExpr) exprNode()        {}
+func (n *WhereExpr) exprNode()        {}
+func (n *IndexExpr) exprNode()        {}
+func (n *BlockExpr) exprNode()        {}
+func (n *BoolLiteral) exprNode()      {}
+func (n *Ident) exprNode()            {}
+func (n *UnaryExpr) exprNode()        {}
+func (n *BinaryExpr) exprNode()       {}
+func (n *CallExpr) exprNode()         {}
+func (n *IfExpr) exprNode()           {}
+
+func (n *NumberLiteral) String() string {
+	return n.Token.Value
+}
+
+func (n *StringLiteral) String() string {
+	return `"` + quote(n.Token.Value) + `"`
+}
+
+func (n *CharacterLiteral) String() string {
+	switch n.Token.Value {
+	case "'":
+		return `'\''`
+	case `"`:
+		return `'"'`
+	}
+	return "'" + quote(n.Token.Value) + "'"
+}
+
+func (n *InterpStringExpr) String() string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, part := range n.Parts {
+		if i%2 == 0 {
+			b.WriteString(quote(part.(*StringLiteral).Token.Value))
+		} else {
+			b.WriteString("${" + part.String() + "}")
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// quote escapes s for use between double quotes, including any ${ that
+// would otherwise start an interpolation. Unprintable characters are
+// written as \u{...} escapes.
+func quote(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '$' && strings.HasPrefix(s[i+1:], "{"):
+			b.WriteString(`\$`)
+		case r == utf8.RuneError:
+			// copy invalid UTF-8 through as it is
+			_, width := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(s[i : i+width])
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\u{%x}`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (n *RecordLiteral) String() string {
+	fields := make([]string, len(n.Keys))
+	for i, key := range n.Keys {
+		fields[i] = key + ": " + n.Values[i].String()
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+func (n *BlockExpr) String() string {
+	parts := make([]string, 0, len(n.Statements)+1)
+	for _, st := range n.Statements {
+		parts = append(parts, st.String())
+	}
+	if n.Result != nil {
+		parts = append(parts, n.Result.String())
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+func (n *MemberExpr) String() string {
+	return fmt.Sprintf("(%s.%s)", n.Object, n.Name)
+}
+
+func (n *LetInExpr) String() string {
+	return fmt.Sprintf("(let %s = %s in %s)", n.Name, n.Value, n.Body)
+}
+
+func (n *WhereExpr) String() string {
+	defs := make([]string, len(n.Names))
+	for i, name := range n.Names {
+		defs[i] = fmt.Sprintf("%s = %s", name, n.Values[i])
+	}
+	return fmt.Sprintf("(%s where %s)", n.Expr, strings.Join(defs, ", "))
+}
+
+func (n *ListExpr) String() string {
+	elements := make([]string, len(n.Elements))
+	for i, e := range n.Elements {
+		elements[i] = e.String()
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (n *IndexExpr) String() string {
+	return fmt.Sprintf("(%s[%s])", n.Object, n.Index)
+}
+
+func (n *BoolLiteral) String() string {
+	return n.Token.Value
+}
+
+func (n *Ident) String() string {
+	return n.Name
+}
+
+func (n *UnaryExpr) String() string {
+	return fmt.Sprintf("(%s%s)", n.Op.Value, n.Operand)
+}
+
+func (n *BinaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", n.Left, n.Op.Value, n.Right)
+}
+
+func (n *CallExpr) String() string {
+	parts := make([]string, 0, len(n.Args)+1)
+	parts = append(parts, n.Callee.String())
+	for _, arg := range n.Args {
+		parts = append(parts, arg.String())
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func (n *IfExpr) String() string {
+	return fmt.Sprintf("(if %s then %s else %s)", n.Cond, n.Then, n.Else)
+}
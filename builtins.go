@@ -0,0 +1,117 @@
+package ged
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// BuiltinValue is a function implemented in Go.
+type BuiltinValue struct {
+	Name string
+	Fn   func(args []Value) (Value, error)
+}
+
+func (v *BuiltinValue) Type() string { return "builtin" }
+
+func (v *BuiltinValue) String() string {
+	return "<builtin " + v.Name + ">"
+}
+
+// NewGlobalEnvironment returns an environment holding the built-in
+// functions, which write their output to out.
+func NewGlobalEnvironment(out io.Writer) *Environment {
+	return globals(func() io.Writer { return out })
+}
+
+// globals builds the built-in environment. out is consulted on every
+// write, so the destination can change after the environment is built.
+func globals(out func() io.Writer) *Environment {
+	env := NewEnvironment()
+	for _, b := range builtins(out) {
+		env.Set(b.Name, b)
+	}
+	return env
+}
+
+func builtins(out func() io.Writer) []*BuiltinValue {
+	return []*BuiltinValue{
+		{Name: "println", Fn: func(args []Value) (Value, error) {
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				parts[i] = arg.String()
+			}
+			_, err := fmt.Fprintln(out(), strings.Join(parts, " "))
+			return nil, err
+		}},
+		{Name: "printf", Fn: func(args []Value) (Value, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("missing format string")
+			}
+			format, ok := args[0].(StringValue)
+			if !ok {
+				return nil, fmt.Errorf("format must be a string, got %s", args[0].Type())
+			}
+			s, err := sprintf(string(format), args[1:])
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.WriteString(out(), s)
+			return nil, err
+		}},
+	}
+}
+
+// sprintf formats args according to format, supporting %s for strings, %d
+// for ints, %f for numbers and %% for a literal percent sign.
+func sprintf(format string, args []Value) (string, error) {
+	var b strings.Builder
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i == len(format) {
+			return "", fmt.Errorf("format ends with a lone %%")
+		}
+		verb := format[i]
+		if verb == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		if next == len(args) {
+			return "", fmt.Errorf("missing argument for %%%c", verb)
+		}
+		arg := args[next]
+		next++
+		switch v := arg.(type) {
+		case StringValue:
+			if verb != 's' {
+				return "", fmt.Errorf("%%%c does not accept a string", verb)
+			}
+			b.WriteString(string(v))
+		case IntValue:
+			switch verb {
+			case 'd':
+				fmt.Fprintf(&b, "%d", int64(v))
+			case 'f':
+				fmt.Fprintf(&b, "%f", float64(v))
+			default:
+				return "", fmt.Errorf("%%%c does not accept an int", verb)
+			}
+		case FloatValue:
+			if verb != 'f' {
+				return "", fmt.Errorf("%%%c does not accept a float", verb)
+			}
+			fmt.Fprintf(&b, "%f", float64(v))
+		default:
+			return "", fmt.Errorf("%%%c does not accept a %s", verb, arg.Type())
+		}
+	}
+	if next < len(args) {
+		return "", fmt.Errorf("too many arguments for format %q", format)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,45 @@
+package ged
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuiltinOutput(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{`println "a" 1 2.5;`, "a 1 2.5\n"},
+		{`printf "Hi, %s! %d %f %%\n" "bob" 3 1.5;`, "Hi, bob! 3 1.500000 %\n"},
+		{`printf "%f" 2;`, "2.000000"},
+		{`let sayHello a = printf "Hi, %s!" a; sayHello "world";`, "Hi, world!"},
+	} {
+		var out bytes.Buffer
+		if _, err := runProgram(t, tt.src, NewGlobalEnvironment(&out)); err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q printed %q, want %q", tt.src, &out, tt.want)
+		}
+	}
+}
+
+func TestBuiltinErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{`printf "%d" "x";`, "runtime error at line 1, col 1: printf: %d does not accept a string"},
+		{`printf "%s";`, "runtime error at line 1, col 1: printf: missing argument for %s"},
+		{`printf "%s" "a" 1;`, `runtime error at line 1, col 1: printf: too many arguments for format "%s"`},
+		{`printf 1;`, "runtime error at line 1, col 1: printf: format must be a string, got int"},
+		{`printf "%s" 1.5;`, "runtime error at line 1, col 1: printf: %s does not accept a float"},
+		{`printf "100%";`, "runtime error at line 1, col 1: printf: format ends with a lone %"},
+	} {
+		var out bytes.Buffer
+		_, err := runProgram(t, tt.src, NewGlobalEnvironment(&out))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	ged "github.com/fedya-eremin/ged-compiler"
+)
+
+const sample = `
+			println (420 + 69);
+			let sayHello name = printf "Hi, %s!\n" name;
+			sayHello "world";
+		`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run handles the command line in args. The source comes from the file
+// named by the only argument, from stdin when that is "-", or from the
+// built-in sample program when there is none. By default it is resolved
+// and run; --dump-tokens and --dump-ast print the result of lexing or
+// parsing it instead, without resolving names.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("ged", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: ged [--dump-tokens | --dump-ast] [file | -]")
+		flags.PrintDefaults()
+	}
+	dumpTokens := flags.Bool("dump-tokens", false, "print the tokens instead of running")
+	dumpAST := flags.Bool("dump-ast", false, "print the syntax tree instead of running")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("expected at most one source file, got %d", flags.NArg())
+	}
+	if *dumpTokens && *dumpAST {
+		return errors.New("--dump-tokens and --dump-ast cannot be combined")
+	}
+	path := flags.Arg(0)
+
+	if *dumpTokens {
+		src, err := load(path, stdin)
+		if err != nil {
+			return err
+		}
+		tokens, err := ged.NewLexer(src).Tokenize()
+		for _, tok := range tokens {
+			fmt.Fprintf(stdout, "%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Value)
+		}
+		if err != nil && isFile(path) {
+			return &ged.FileError{Path: path, Err: err}
+		}
+		return err
+	}
+	if *dumpAST {
+		// the tree is dumped as parsed, so names need not resolve
+		src, err := load(path, stdin)
+		if err != nil {
+			return err
+		}
+		program, err := ged.NewParser(ged.NewLexer(src)).ParseProgram()
+		if err != nil {
+			if isFile(path) {
+				return &ged.FileError{Path: path, Err: err}
+			}
+			return err
+		}
+		fmt.Fprintln(stdout, program)
+		return nil
+	}
+	interp := ged.NewInterpreter()
+	interp.Out = stdout
+	program, err := compile(interp, path, stdin)
+	if err != nil {
+		return err
+	}
+	_, err = interp.Run(program)
+	return err
+}
+
+func isFile(path string) bool {
+	return path != "" && path != "-"
+}
+
+func load(path string, stdin io.Reader) (string, error) {
+	switch path {
+	case "":
+		return sample, nil
+	case "-":
+		src, err := io.ReadAll(stdin)
+		return string(src), err
+	default:
+		src, err := os.ReadFile(path)
+		return string(src), err
+	}
+}
+
+// compile parses and resolves the source named by path for interp,
+// attributing errors to the file when there is one. Source from stdin and
+// the sample go through the same checks as a file.
+func compile(interp *ged.Interpreter, path string, stdin io.Reader) (*ged.Program, error) {
+	if isFile(path) {
+		return interp.CompileFile(path)
+	}
+	src, err := load(path, stdin)
+	if err != nil {
+		return nil, err
+	}
+	return interp.Compile(src)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		args  []string
+		stdin string
+		want  string
+	}{
+		{"sample", nil, "", "489\nHi, world!\n"},
+		{"stdin", []string{"-"}, "println 1;", "1\n"},
+		{"dump tokens", []string{"--dump-tokens", "-"}, "let x =\n  1;", "1:1\tlet\t\"let\"\n1:5\tidentifier\t\"x\"\n1:7\teq\t\"=\"\n2:3\tintNumber\t\"1\"\n2:4\tsemicolon\t\";\"\n2:5\teof\t\"\"\n"},
+		{"dump ast", []string{"--dump-ast", "-"}, "let x = 1 + 2 * 3; f x;", "let x = (1 + (2 * 3));\n(f x);\n"},
+		{"dump ast with undefined names", []string{"--dump-ast", "-"}, "println y;", "(println y);\n"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if err := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if stdout.String() != tt.want {
+			t.Errorf("%s: printed %q, want %q", tt.name, &stdout, tt.want)
+		}
+	}
+}
+
+func TestRunDumpsSample(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"--dump-tokens"}, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2:4\tidentifier\t\"println\"\n2:12\tlparen\t\"(\"\n"; !strings.HasPrefix(stdout.String(), want) {
+		t.Errorf("--dump-tokens printed %q, want it to start with %q", &stdout, want)
+	}
+	stdout.Reset()
+	if err := run([]string{"--dump-ast"}, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	want := "(println (420 + 69));\nlet sayHello name = (printf \"Hi, %s!\\n\" name);\n(sayHello \"world\");\n"
+	if stdout.String() != want {
+		t.Errorf("--dump-ast printed %q, want %q", &stdout, want)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.ged")
+	if err := os.WriteFile(path, []byte("let x = 1;\nx @"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--dump-ast", "--dump-tokens"}, "--dump-tokens and --dump-ast cannot be combined"},
+		{[]string{"a.ged", "b.ged"}, "expected at most one source file, got 2"},
+		{[]string{"--bogus"}, "flag provided but not defined: -bogus"},
+		{[]string{"--dump-tokens", path}, path + ": lex error at line 2, col 3: unknown token '@'"},
+		{[]string{path}, path + ": lex error at line 2, col 3: unknown token '@'"},
+		{[]string{"--dump-ast", path}, path + ": lex error at line 2, col 3: unknown token '@'"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if err := run(tt.args, strings.NewReader(""), &stdout, &stderr); err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestRunDumpTokensPrintsUpToError(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"--dump-tokens", "-"}, strings.NewReader("a b @"), &stdout, &stderr)
+	if err == nil || err.Error() != "lex error at line 1, col 5: unknown token '@'" {
+		t.Errorf("got error %v", err)
+	}
+	if want := "1:1\tidentifier\t\"a\"\n1:3\tidentifier\t\"b\"\n"; stdout.String() != want {
+		t.Errorf("printed %q, want %q", &stdout, want)
+	}
+}
+
+func TestRunHelp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-h"}, nil, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stderr.String(), "usage: ged [--dump-tokens | --dump-ast] [file | -]\n") {
+		t.Errorf("-h printed %q, want the usage line", &stderr)
+	}
+}
+
+// TestRunResolvesEveryInput checks that source from stdin goes through the
+// same checks as a file before anything runs, and that --dump-ast prints
+// either without resolving names.
+func TestRunResolvesEveryInput(t *testing.T) {
+	src := "println 1; println y;"
+	path := filepath.Join(t.TempDir(), "y.ged")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		arg, want string
+	}{
+		{"-", "resolve error at line 1, col 20: undefined variable y"},
+		{path, path + ": resolve error at line 1, col 20: undefined variable y"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if err := run([]string{tt.arg}, strings.NewReader(src), &stdout, &stderr); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: got error %v, want %s", tt.arg, err, tt.want)
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("%s: printed %q before failing", tt.arg, &stdout)
+		}
+		stdout.Reset()
+		if err := run([]string{"--dump-ast", tt.arg}, strings.NewReader(src), &stdout, &stderr); err != nil {
+			t.Errorf("--dump-ast %s: %v", tt.arg, err)
+		}
+		if want := "(println 1);\n(println y);\n"; stdout.String() != want {
+			t.Errorf("--dump-ast %s: printed %q, want %q", tt.arg, &stdout, want)
+		}
+	}
+}
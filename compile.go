@@ -0,0 +1,139 @@
+package ged
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// FileError attributes a lex or parse error to the file it occurred in.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// CompileError reports a problem found before a program runs, such as a
+// constant 1 / 0 or a name that is never bound.
+type CompileError struct {
+	// Phase names the stage that found the problem: "lex", "parse",
+	// "resolve", or "compile" when left empty.
+	Phase string
+	// Path is the file the problem is in, if known.
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *CompileError) Error() string {
+	phase := e.Phase
+	if phase == "" {
+		phase = "compile"
+	}
+	msg := fmt.Sprintf("%s error at line %d, col %d: %s", phase, e.Line, e.Column, e.Message)
+	if e.Path != "" {
+		return e.Path + ": " + msg
+	}
+	return msg
+}
+
+func compileErrorf(tok Token, format string, args ...any) error {
+	return &CompileError{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)}
+}
+
+// CompileErrors holds every problem found in a program, ordered by
+// position.
+type CompileErrors []CompileError
+
+func (e CompileErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e CompileErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i := range e {
+		errs[i] = &e[i]
+	}
+	return errs
+}
+
+// CompileFile reads the source file at path, parses it and resolves its
+// names. A failure to read the file is returned as is. A single problem is
+// wrapped in a FileError naming path, inside a ParseErrors if it is a
+// syntax error; several are returned as CompileErrors.
+func CompileFile(path string) (*Program, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return compile(string(src), path)
+}
+
+// compile runs the front end over source, attributing problems to path
+// unless it is empty. Names are only resolved once the program parses, as a
+// broken statement would otherwise leave spurious undefined names behind;
+// predeclared names count as bound.
+func compile(source, path string, predeclared ...string) (*Program, error) {
+	program, err := NewParser(NewLexer(source)).ParseProgram()
+	var problems []error
+	if err != nil {
+		var errs ParseErrors
+		if !errors.As(err, &errs) {
+			return nil, err
+		}
+		problems = errs
+	} else {
+		problems = Resolve(program, predeclared...)
+	}
+	switch len(problems) {
+	case 0:
+		return program, nil
+	case 1:
+		if path != "" {
+			problems[0] = &FileError{Path: path, Err: problems[0]}
+		}
+		if err != nil {
+			return nil, ParseErrors(problems)
+		}
+		return nil, problems[0]
+	}
+	diags := make(CompileErrors, len(problems))
+	for i, problem := range problems {
+		diags[i] = compileErrorOf(problem)
+		diags[i].Path = path
+	}
+	slices.SortStableFunc(diags, func(a, b CompileError) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	return nil, diags
+}
+
+func compileErrorOf(err error) CompileError {
+	var lexErr *LexError
+	var parseErr *ParseError
+	var compileErr *CompileError
+	switch {
+	case errors.As(err, &lexErr):
+		return CompileError{Phase: "lex", Line: lexErr.Line, Column: lexErr.Column, Message: lexErr.Message}
+	case errors.As(err, &parseErr):
+		return CompileError{Phase: "parse", Line: parseErr.Line, Column: parseErr.Column, Message: parseErr.Message}
+	case errors.As(err, &compileErr):
+		return *compileErr
+	}
+	return CompileError{Message: err.Error()}
+}
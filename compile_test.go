@@ -0,0 +1,96 @@
+package ged
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeFile writes src to a file named name in a temporary directory and
+// returns its path.
+func writeFile(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCompileFile(t *testing.T) {
+	program, err := CompileFile(writeFile(t, "sample.ged", runSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(program.Statements) != 3 {
+		t.Errorf("parsed %d statements, want 3:\n%s", len(program.Statements), program)
+	}
+}
+
+func TestCompileFileErrors(t *testing.T) {
+	if _, err := CompileFile(filepath.Join(t.TempDir(), "missing.ged")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got error %v for a missing file, want fs.ErrNotExist", err)
+	}
+
+	path := writeFile(t, "bad.ged", "let x = 1;\nx = y;")
+	_, err := CompileFile(path)
+	var fileErr *FileError
+	if !errors.As(err, &fileErr) || fileErr.Path != path {
+		t.Fatalf("got error %v, want a *FileError for %s", err, path)
+	}
+	if want := path + ": resolve error at line 2, col 5: undefined variable y"; err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+
+	path = writeFile(t, "bad.ged", "let = 1;\nlet y 2;")
+	_, err = CompileFile(path)
+	var errs CompileErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Path != path {
+		t.Fatalf("got error %v, want CompileErrors for %s", err, path)
+	}
+	want := path + `: parse error at line 1, col 5: expected identifier, found "="` + "\n" +
+		path + `: parse error at line 2, col 7: expected eq, found "2"`
+	if err.Error() != want {
+		t.Errorf("got error\n%v\nwant\n%s", err, want)
+	}
+}
+
+func TestCompileErrorsSorted(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []string
+	}{
+		{"let = 1;\nlet y 2;\nf @", []string{
+			`parse error at line 1, col 5: expected identifier, found "="`,
+			`parse error at line 2, col 7: expected eq, found "2"`,
+			"lex error at line 3, col 3: unknown token '@'",
+		}},
+		{"let f a = b + a;\nprintln (d + c) d;", []string{
+			"resolve error at line 1, col 11: undefined variable b",
+			"resolve error at line 2, col 10: undefined variable d",
+			"resolve error at line 2, col 14: undefined variable c",
+			"resolve error at line 2, col 17: undefined variable d",
+		}},
+	} {
+		err := RunWithOutput(tt.src, nil)
+		var errs CompileErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%q: got error %v, want CompileErrors", tt.src, err)
+			continue
+		}
+		var got []string
+		for _, e := range errs {
+			got = append(got, e.Error())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+		var first *CompileError
+		if !errors.As(err, &first) || first.Error() != tt.want[0] {
+			t.Errorf("%q: errors.As found %v, want the first problem", tt.src, first)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package ged
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+type Opcode byte
+
+const (
+	// OpConstant pushes the constant whose index follows.
+	OpConstant Opcode = iota
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpNeg
+	// OpGetGlobal and OpSetGlobal load and store the global whose slot
+	// follows.
+	OpGetGlobal
+	OpSetGlobal
+	// OpPop discards the top of the stack, which becomes the program's
+	// result.
+	OpPop
+)
+
+var opNames = [...]string{
+	OpConstant:  "OpConstant",
+	OpAdd:       "OpAdd",
+	OpSub:       "OpSub",
+	OpMul:       "OpMul",
+	OpDiv:       "OpDiv",
+	OpNeg:       "OpNeg",
+	OpGetGlobal: "OpGetGlobal",
+	OpSetGlobal: "OpSetGlobal",
+	OpPop:       "OpPop",
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opNames) && opNames[op] != "" {
+		return opNames[op]
+	}
+	return fmt.Sprintf("Opcode(%d)", int(op))
+}
+
+// operandWidth is the size in bytes of each opcode's operand, zero for
+// opcodes that take none. Operands are big-endian.
+func operandWidth(op Opcode) int {
+	switch op {
+	case OpConstant, OpGetGlobal, OpSetGlobal:
+		return 2
+	}
+	return 0
+}
+
+// Instructions is encoded bytecode: each opcode is followed by its operand.
+type Instructions []byte
+
+// String disassembles the instructions one per line, prefixed with their
+// offsets.
+func (ins Instructions) String() string {
+	var b strings.Builder
+	for i := 0; i < len(ins); {
+		op := Opcode(ins[i])
+		fmt.Fprintf(&b, "%04d %s", i, op)
+		if operandWidth(op) == 2 {
+			fmt.Fprintf(&b, " %d", binary.BigEndian.Uint16(ins[i+1:]))
+		}
+		b.WriteByte('\n')
+		i += 1 + operandWidth(op)
+	}
+	return b.String()
+}
+
+// Bytecode is a compiled program.
+type Bytecode struct {
+	Instructions Instructions
+	// Positions holds, for each instruction in order, where in the source
+	// it was compiled from, or the zero Position where that is unknown.
+	Positions []Position
+	Constants []Value
+	// Globals names each global slot.
+	Globals []string
+}
+
+// Position is a one-based line and column in the source.
+type Position struct {
+	Line, Column int
+}
+
+// position returns the position in the source of the instruction at
+// offset, or the zero Position if offset does not start one.
+func (code *Bytecode) position(offset int) Position {
+	for i, n := 0, 0; i < len(code.Instructions) && n < len(code.Positions); n++ {
+		if i == offset {
+			return code.Positions[n]
+		}
+		i += 1 + operandWidth(Opcode(code.Instructions[i]))
+	}
+	return Position{}
+}
+
+var binaryOps = map[TokenType]Opcode{
+	Plus:     OpAdd,
+	Minus:    OpSub,
+	Asterisk: OpMul,
+	Slash:    OpDiv,
+}
+
+type compiler struct {
+	code    *Bytecode
+	symbols map[string]int
+}
+
+// Compile translates program into bytecode for the VM. So far only let
+// bindings, assignments and arithmetic on numbers and names are supported.
+func Compile(program *Program) (*Bytecode, error) {
+	c := &compiler{code: &Bytecode{}, symbols: make(map[string]int)}
+	for _, st := range program.Statements {
+		if err := c.statement(st); err != nil {
+			return nil, err
+		}
+	}
+	return c.code, nil
+}
+
+func (c *compiler) statement(st Statement) error {
+	switch s := st.(type) {
+	case *LetStatement:
+		if err := c.expr(s.Value); err != nil {
+			return err
+		}
+		if _, ok := c.symbols[s.Name]; !ok {
+			c.symbols[s.Name] = len(c.code.Globals)
+			c.code.Globals = append(c.code.Globals, s.Name)
+		}
+		c.emit(s.Token, OpSetGlobal, c.symbols[s.Name])
+	case *AssignStatement:
+		slot, ok := c.symbols[s.Name]
+		if !ok {
+			return compileErrorf(s.Token, "assignment to undefined variable %s", s.Name)
+		}
+		if err := c.expr(s.Value); err != nil {
+			return err
+		}
+		c.emit(s.Token, OpSetGlobal, slot)
+	case *ExpressionStatement:
+		if err := c.expr(s.Expr); err != nil {
+			return err
+		}
+		c.emit(Token{}, OpPop)
+	default:
+		return fmt.Errorf("cannot compile %T", st)
+	}
+	return nil
+}
+
+func (c *compiler) expr(e Expr) error {
+	switch n := e.(type) {
+	case *NumberLiteral:
+		v, err := numberValue(n.Token)
+		if err != nil {
+			return err
+		}
+		c.constant(n.Token, v)
+	case *StringLiteral, *CharacterLiteral:
+		tok := literalToken(n)
+		c.constant(tok, StringValue(tok.Value))
+	case *Ident:
+		slot, ok := c.symbols[n.Name]
+		if !ok {
+			return compileErrorf(n.Token, "undefined variable %s", n.Name)
+		}
+		c.emit(n.Token, OpGetGlobal, slot)
+	case *UnaryExpr:
+		if n.Op.Type != Minus {
+			return compileErrorf(n.Op, "cannot compile operator %s", n.Op.Value)
+		}
+		if err := c.expr(n.Operand); err != nil {
+			return err
+		}
+		c.emit(n.Op, OpNeg)
+	case *BinaryExpr:
+		op, ok := binaryOps[n.Op.Type]
+		if !ok {
+			return compileErrorf(n.Op, "cannot compile operator %s", n.Op.Value)
+		}
+		if err := c.expr(n.Left); err != nil {
+			return err
+		}
+		if err := c.expr(n.Right); err != nil {
+			return err
+		}
+		c.emit(n.Op, op)
+	default:
+		return fmt.Errorf("cannot compile %T", e)
+	}
+	return nil
+}
+
+func (c *compiler) constant(tok Token, v Value) {
+	c.emit(tok, OpConstant, len(c.code.Constants))
+	c.code.Constants = append(c.code.Constants, v)
+}
+
+// emit appends an instruction compiled from tok.
+func (c *compiler) emit(tok Token, op Opcode, operand ...int) {
+	c.code.Instructions = append(c.code.Instructions, byte(op))
+	c.code.Positions = append(c.code.Positions, Position{tok.Line, tok.Column})
+	if len(operand) > 0 {
+		c.code.Instructions = binary.BigEndian.AppendUint16(c.code.Instructions, uint16(operand[0]))
+	}
+}
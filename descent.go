@@ -0,0 +1,116 @@
+package ged
+
+import "slices"
+
+// ParserKind selects how a parser made by NewProgramParser handles binary
+// operators. Both kinds accept the same grammar and build the same trees.
+type ParserKind int
+
+const (
+	// PrecedenceClimbing parses operators with a single loop driven by
+	// their binding powers in the precedences table, as NewParser does.
+	PrecedenceClimbing ParserKind = iota
+	// RecursiveDescent gives each level of binding power a function of its
+	// own, which is longer but easier to follow and extend by hand.
+	RecursiveDescent
+)
+
+// ProgramParser parses a whole program from its tokens, as returned by
+// Tokenize.
+type ProgramParser interface {
+	Parse(tokens []Token) (*Program, error)
+}
+
+// NewProgramParser returns a parser of the given kind.
+func NewProgramParser(kind ParserKind) ProgramParser {
+	return tokenParser{kind: kind}
+}
+
+type tokenParser struct {
+	kind ParserKind
+}
+
+func (tp tokenParser) Parse(tokens []Token) (*Program, error) {
+	p := &Parser{tokens: tokens, kind: tp.kind}
+	p.advance()
+	return p.ParseProgram()
+}
+
+// The recursive-descent parser goes from the loosest operators down to the
+// tightest. Each function parses a chain of the operators at its level,
+// taking its operands from the next level down, and the last hands over to
+// parseUnary like the precedence-climbing parser does. Any operator added to
+// precedences needs a place here too.
+
+// parsePipe parses a chain of |>, each rewritten into a call.
+func (p *Parser) parsePipe() (Expr, error) {
+	start := p.tok.Offset
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.Type == Pipe {
+		op := p.tok
+		p.advance()
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = p.pipeCall(start, op, left, right)
+	}
+	return left, nil
+}
+
+func (p *Parser) parseOr() (Expr, error) {
+	return p.parseLeftAssociative(p.parseAnd, Or)
+}
+
+func (p *Parser) parseAnd() (Expr, error) {
+	return p.parseLeftAssociative(p.parseComparison, And)
+}
+
+func (p *Parser) parseComparison() (Expr, error) {
+	return p.parseLeftAssociative(p.parseSum, Equal, NotEqual, Less, LessEqual, Greater, GreaterEqual)
+}
+
+func (p *Parser) parseSum() (Expr, error) {
+	return p.parseLeftAssociative(p.parseProduct, Plus, Minus)
+}
+
+func (p *Parser) parseProduct() (Expr, error) {
+	return p.parseLeftAssociative(p.parsePower, Asterisk, Slash, Percent)
+}
+
+// parsePower parses a chain of **, which groups from the right.
+func (p *Parser) parsePower() (Expr, error) {
+	start := p.tok.Offset
+	left, err := p.parseUnary()
+	if err != nil || p.tok.Type != Power {
+		return left, err
+	}
+	op := p.tok
+	p.advance()
+	right, err := p.parsePower()
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryExpr{nodeBase: p.spanFrom(start), Op: op, Left: left, Right: right}, nil
+}
+
+func (p *Parser) parseLeftAssociative(operand func() (Expr, error), ops ...TokenType) (Expr, error) {
+	start := p.tok.Offset
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for slices.Contains(ops, p.tok.Type) {
+		op := p.tok
+		p.advance()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{nodeBase: p.spanFrom(start), Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
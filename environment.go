@@ -0,0 +1,49 @@
+package ged
+
+// Environment maps names to values. Lookups that miss fall back to the
+// enclosing environment, giving lexical scoping.
+type Environment struct {
+	store  map[string]Value
+	parent *Environment
+	// checked turns integer overflow and non-finite float results into
+	// errors. Nested scopes inherit it.
+	checked bool
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Value)}
+}
+
+// NewEnclosed returns an empty scope nested inside parent.
+func NewEnclosed(parent *Environment) *Environment {
+	env := NewEnvironment()
+	env.parent = parent
+	env.checked = parent.checked
+	return env
+}
+
+func (e *Environment) Get(name string) (Value, bool) {
+	for env := e; env != nil; env = env.parent {
+		if v, ok := env.store[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Set binds name in this scope, shadowing any binding in an enclosing one.
+func (e *Environment) Set(name string, v Value) {
+	e.store[name] = v
+}
+
+// Assign rebinds name in the nearest scope that defines it, reporting
+// whether there was one.
+func (e *Environment) Assign(name string, v Value) bool {
+	for env := e; env != nil; env = env.parent {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = v
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package ged
+
+import "testing"
+
+func TestEnvironmentScopes(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", IntValue(1))
+	outer.Set("y", IntValue(2))
+	inner := NewEnclosed(outer)
+	inner.Set("x", IntValue(10))
+	for _, tt := range []struct {
+		env  *Environment
+		name string
+		want Value
+	}{
+		{inner, "x", IntValue(10)},
+		{inner, "y", IntValue(2)},
+		{outer, "x", IntValue(1)},
+		{inner, "z", nil},
+		{outer, "z", nil},
+	} {
+		got, ok := tt.env.Get(tt.name)
+		if got != tt.want || ok != (tt.want != nil) {
+			t.Errorf("Get(%q) = %v, %t, want %v", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", IntValue(1))
+	inner := NewEnclosed(outer)
+	if !inner.Assign("x", IntValue(7)) {
+		t.Fatal("Assign of x through an inner scope failed")
+	}
+	if got, _ := outer.Get("x"); got != IntValue(7) {
+		t.Errorf("outer x = %v after assigning through an inner scope, want 7", got)
+	}
+	if inner.Assign("y", IntValue(2)) {
+		t.Error("Assign of an undefined name succeeded")
+	}
+	if _, ok := inner.Get("y"); ok {
+		t.Error("failed Assign bound y")
+	}
+}
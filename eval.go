@@ -0,0 +1,737 @@
+package ged
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Value is the result of evaluating an expression.
+type Value interface {
+	// Type names the kind of value for error messages.
+	Type() string
+	String() string
+}
+
+type IntValue int64
+type FloatValue float64
+type StringValue string
+type BoolValue bool
+
+// ListValue is a list of values, which may be of different types.
+type ListValue []Value
+
+// RecordValue holds the fields of a record, Keys[i] naming Values[i] in the
+// order they were written.
+type RecordValue struct {
+	Keys   []string
+	Values []Value
+}
+
+// FunctionValue is a user-defined function closed over the environment it
+// was defined in.
+type FunctionValue struct {
+	Name   string
+	Params []string
+	Body   Expr
+	Env    *Environment
+}
+
+func (v IntValue) Type() string       { return "int" }
+func (v FloatValue) Type() string     { return "float" }
+func (v StringValue) Type() string    { return "string" }
+func (v BoolValue) Type() string      { return "bool" }
+func (v ListValue) Type() string      { return "list" }
+func (v *RecordValue) Type() string   { return "record" }
+func (v *FunctionValue) Type() string { return "function" }
+
+func (v IntValue) String() string {
+	return strconv.FormatInt(int64(v), 10)
+}
+
+func (v FloatValue) String() string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 64)
+}
+
+func (v StringValue) String() string {
+	return string(v)
+}
+
+func (v BoolValue) String() string {
+	return strconv.FormatBool(bool(v))
+}
+
+func (v ListValue) String() string {
+	elements := make([]string, len(v))
+	for i, e := range v {
+		elements[i] = e.String()
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (v *RecordValue) String() string {
+	fields := make([]string, len(v.Keys))
+	for i, key := range v.Keys {
+		fields[i] = key + ": " + v.Values[i].String()
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// Field returns the value of the field called name, if the record has one.
+func (v *RecordValue) Field(name string) (Value, bool) {
+	if i := slices.Index(v.Keys, name); i >= 0 {
+		return v.Values[i], true
+	}
+	return nil, false
+}
+
+func (v *FunctionValue) String() string {
+	return "<function " + v.Name + ">"
+}
+
+// RuntimeError reports a failure while evaluating, at the position of the
+// token that caused it.
+type RuntimeError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("runtime error at line %d, col %d: %s", e.Line, e.Column, e.Message)
+}
+
+func runtimeErrorf(tok Token, format string, args ...any) error {
+	return &RuntimeError{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)}
+}
+
+// EvalProgram runs each statement in env and returns the value of the last
+// one, or nil if it produced none.
+func EvalProgram(program *Program, env *Environment) (Value, error) {
+	var result Value
+	for _, st := range program.Statements {
+		v, err := EvalStatement(st, env)
+		if err != nil {
+			return nil, err
+		}
+		result = v
+	}
+	return result, nil
+}
+
+// EvalStatement runs st in env. Bindings and assignments produce no value.
+func EvalStatement(st Statement, env *Environment) (Value, error) {
+	switch s := st.(type) {
+	case *LetStatement:
+		v, err := Eval(s.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		env.Set(s.Name, v)
+		return nil, nil
+	case *FunctionDef:
+		env.Set(s.Name, &FunctionValue{Name: s.Name, Params: s.Params, Body: s.Body, Env: env})
+		return nil, nil
+	case *AssignStatement:
+		v, err := Eval(s.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		if !env.Assign(s.Name, v) {
+			return nil, runtimeErrorf(s.Token, "assignment to undefined variable %s", s.Name)
+		}
+		return nil, nil
+	case *ExpressionStatement:
+		return Eval(s.Expr, env)
+	default:
+		return nil, fmt.Errorf("cannot evaluate %T", st)
+	}
+}
+
+// Eval computes the value of node in env. Arithmetic mixing ints and floats
+// promotes to float, and / always produces a float. + also joins two
+// strings; mixing a string with a number is an error rather than a
+// conversion, as interpolation already covers building text from numbers.
+// Comparisons produce bools; see equal for how == treats values of
+// different types.
+func Eval(node Expr, env *Environment) (Value, error) {
+	switch n := node.(type) {
+	case *NumberLiteral:
+		return numberValue(n.Token)
+	case *StringLiteral:
+		return StringValue(n.Token.Value), nil
+	case *CharacterLiteral:
+		return StringValue(n.Token.Value), nil
+	case *BoolLiteral:
+		if b, ok := n.Token.Parsed.(bool); ok {
+			return BoolValue(b), nil
+		}
+		return BoolValue(n.Token.Value == "true"), nil
+	case *InterpStringExpr:
+		var b strings.Builder
+		for _, part := range n.Parts {
+			v, err := Eval(part, env)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				return nil, runtimeErrorf(n.Token, "interpolated expression %s has no value", part)
+			}
+			b.WriteString(v.String())
+		}
+		return StringValue(b.String()), nil
+	case *Ident:
+		v, ok := env.Get(n.Name)
+		if !ok {
+			return nil, runtimeErrorf(n.Token, "undefined variable %s", n.Name)
+		}
+		return v, nil
+	case *UnaryExpr:
+		return evalUnary(n, env)
+	case *BinaryExpr:
+		if n.Op.Type == And || n.Op.Type == Or {
+			return evalLogical(n, env)
+		}
+		left, err := Eval(n.Left, env)
+		if err != nil {
+			return nil, err
+		}
+		right, err := Eval(n.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		v, err := infix(n.Op, left, right)
+		if err == nil && env.checked {
+			err = checkArithmetic(n.Op, left, right, v)
+		}
+		return v, err
+	case *IfExpr, *BlockExpr, *LetInExpr, *WhereExpr, *CallExpr:
+		v, call, callEnv, err := evalTail(node, env)
+		if err != nil || call == nil {
+			return v, err
+		}
+		return evalCall(call, callEnv)
+	case *ListExpr:
+		list := make(ListValue, len(n.Elements))
+		for i, e := range n.Elements {
+			v, err := Eval(e, env)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				return nil, runtimeErrorf(n.Token, "list element %s has no value", e)
+			}
+			list[i] = v
+		}
+		return list, nil
+	case *IndexExpr:
+		return evalIndex(n, env)
+	case *RecordLiteral:
+		record := &RecordValue{Keys: n.Keys, Values: make([]Value, len(n.Values))}
+		for i, e := range n.Values {
+			if slices.Index(n.Keys, n.Keys[i]) < i {
+				return nil, runtimeErrorf(n.Token, "duplicate field %s in record", n.Keys[i])
+			}
+			v, err := Eval(e, env)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				return nil, runtimeErrorf(n.Token, "field %s has no value", n.Keys[i])
+			}
+			record.Values[i] = v
+		}
+		return record, nil
+	case *MemberExpr:
+		object, err := Eval(n.Object, env)
+		if err != nil {
+			return nil, err
+		}
+		record, ok := object.(*RecordValue)
+		if !ok {
+			return nil, runtimeErrorf(n.Token, "cannot read field %s of %s value %s", n.Name, typeName(object), n.Object)
+		}
+		v, ok := record.Field(n.Name)
+		if !ok {
+			return nil, runtimeErrorf(n.Token, "record has no field %s", n.Name)
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("cannot evaluate %T", node)
+	}
+}
+
+// evalTail evaluates node, except that a call whose result would be the
+// value of node, one in tail position, is returned unmade along with the
+// environment to make it in. The branches of an if, the result of a block
+// and the body of a let ... in are in tail position.
+func evalTail(node Expr, env *Environment) (Value, *CallExpr, *Environment, error) {
+	for {
+		switch n := node.(type) {
+		case *CallExpr:
+			return nil, n, env, nil
+		case *IfExpr:
+			cond, err := Eval(n.Cond, env)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			c, ok := cond.(BoolValue)
+			if !ok {
+				return nil, nil, nil, runtimeErrorf(n.Token, "if condition must be bool, got %s", typeName(cond))
+			}
+			node = n.Else
+			if c {
+				node = n.Then
+			}
+		case *BlockExpr:
+			scope := NewEnclosed(env)
+			for _, st := range n.Statements {
+				if _, err := EvalStatement(st, scope); err != nil {
+					return nil, nil, nil, err
+				}
+			}
+			if n.Result == nil {
+				return nil, nil, nil, nil
+			}
+			node, env = n.Result, scope
+		case *LetInExpr:
+			v, err := Eval(n.Value, env)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			scope := NewEnclosed(env)
+			scope.Set(n.Name, v)
+			node, env = n.Body, scope
+		case *WhereExpr:
+			scope := NewEnclosed(env)
+			for i, name := range n.Names {
+				v, err := Eval(n.Values[i], scope)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				scope.Set(name, v)
+			}
+			node, env = n.Expr, scope
+		default:
+			v, err := Eval(node, env)
+			return v, nil, nil, err
+		}
+	}
+}
+
+// evalCall makes the call n. A call in tail position of the function body
+// replaces the current one instead of nesting inside it, so tail recursion
+// runs in constant Go stack however deep it goes.
+func evalCall(n *CallExpr, env *Environment) (Value, error) {
+	for {
+		callee, err := Eval(n.Callee, env)
+		if err != nil {
+			return nil, err
+		}
+		args := make([]Value, len(n.Args))
+		for i, arg := range n.Args {
+			if args[i], err = Eval(arg, env); err != nil {
+				return nil, err
+			}
+			if args[i] == nil {
+				return nil, runtimeErrorf(n.Token, "argument %s to %s has no value", arg, n.Callee)
+			}
+		}
+		if b, ok := callee.(*BuiltinValue); ok {
+			v, err := b.Fn(args)
+			if err != nil {
+				return nil, runtimeErrorf(n.Token, "%s: %v", b.Name, err)
+			}
+			return v, nil
+		}
+		fn, ok := callee.(*FunctionValue)
+		if !ok {
+			return nil, runtimeErrorf(n.Token, "cannot call %s value %s", typeName(callee), n.Callee)
+		}
+		if len(args) != len(fn.Params) {
+			return nil, runtimeErrorf(n.Token, "%s expects %d arguments, got %d", fn.Name, len(fn.Params), len(args))
+		}
+		scope := NewEnclosed(fn.Env)
+		for i, name := range fn.Params {
+			scope.Set(name, args[i])
+		}
+		v, call, callEnv, err := evalTail(fn.Body, scope)
+		if err != nil || call == nil {
+			return v, err
+		}
+		n, env = call, callEnv
+	}
+}
+
+func evalIndex(n *IndexExpr, env *Environment) (Value, error) {
+	object, err := Eval(n.Object, env)
+	if err != nil {
+		return nil, err
+	}
+	index, err := Eval(n.Index, env)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := object.(ListValue)
+	if !ok {
+		return nil, runtimeErrorf(n.Token, "cannot index %s value %s", typeName(object), n.Object)
+	}
+	i, ok := index.(IntValue)
+	if !ok {
+		return nil, runtimeErrorf(n.Token, "list index must be int, got %s", typeName(index))
+	}
+	if i < 0 || int64(i) >= int64(len(list)) {
+		return nil, runtimeErrorf(n.Token, "index %d out of range for list of length %d", i, len(list))
+	}
+	return list[i], nil
+}
+
+// numberValue returns the value of a number literal, which the lexer has
+// usually parsed already. Tokens made some other way are parsed here.
+func numberValue(tok Token) (Value, error) {
+	parsed := tok.Parsed
+	if parsed == nil {
+		var err error
+		if parsed, err = parseNumber(tok.Type, tok.Value); err != nil {
+			if tok.Type == FloatNumber {
+				return nil, runtimeErrorf(tok, "invalid float literal %s", tok.Value)
+			}
+			return nil, runtimeErrorf(tok, "invalid integer literal %s", tok.Value)
+		}
+	}
+	switch v := parsed.(type) {
+	case int64:
+		return IntValue(v), nil
+	case float64:
+		return FloatValue(v), nil
+	}
+	return nil, runtimeErrorf(tok, "invalid number literal %s", tok.Value)
+}
+
+func evalUnary(n *UnaryExpr, env *Environment) (Value, error) {
+	operand, err := Eval(n.Operand, env)
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := operand.(IntValue); ok && env.checked && n.Op.Type == Minus && i == math.MinInt64 {
+		return nil, runtimeErrorf(n.Op, "integer overflow in -(%s)", i)
+	}
+	return unary(n.Op, operand)
+}
+
+func unary(op Token, operand Value) (Value, error) {
+	switch v := operand.(type) {
+	case IntValue:
+		if op.Type == Minus {
+			return -v, nil
+		}
+	case FloatValue:
+		if op.Type == Minus {
+			return -v, nil
+		}
+	case BoolValue:
+		if op.Type == Bang {
+			return !v, nil
+		}
+	}
+	return nil, runtimeErrorf(op, "unsupported operand type for %s: %s", op.Value, typeName(operand))
+}
+
+// evalLogical evaluates && and ||, skipping the right operand once the left
+// one decides the result.
+func evalLogical(n *BinaryExpr, env *Environment) (Value, error) {
+	left, err := Eval(n.Left, env)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := left.(BoolValue)
+	if !ok {
+		return nil, runtimeErrorf(n.Op, "%s expects bool operands, got %s", n.Op.Value, typeName(left))
+	}
+	if bool(l) == (n.Op.Type == Or) {
+		return l, nil
+	}
+	right, err := Eval(n.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := right.(BoolValue)
+	if !ok {
+		return nil, runtimeErrorf(n.Op, "%s expects bool operands, got %s", n.Op.Value, typeName(right))
+	}
+	return r, nil
+}
+
+// typeName is v's type for error messages, allowing for the nil produced by
+// statements and calls that return nothing.
+func typeName(v Value) string {
+	if v == nil {
+		return "nothing"
+	}
+	return v.Type()
+}
+
+func infix(op Token, left, right Value) (Value, error) {
+	switch op.Type {
+	case Equal, NotEqual:
+		if left == nil || right == nil {
+			return nil, unsupportedOperands(op, left, right)
+		}
+		return BoolValue(equal(left, right) == (op.Type == Equal)), nil
+	case Less, LessEqual, Greater, GreaterEqual:
+		return compare(op, left, right)
+	}
+	return arithmetic(op, left, right)
+}
+
+// equal reports whether two values are the same. Ints and floats are
+// compared as numbers, so 2 == 2.0, just as arithmetic mixes them. Any other
+// values of different types are simply unequal rather than an error, so
+// x == "none" can test what x holds. Lists are equal when their elements
+// are, records when they have the same fields with equal values in any
+// order, and functions are equal only to themselves.
+func equal(left, right Value) bool {
+	switch l := left.(type) {
+	case ListValue:
+		r, ok := right.(ListValue)
+		return ok && slices.EqualFunc(l, r, equal)
+	case *RecordValue:
+		r, ok := right.(*RecordValue)
+		if !ok || len(l.Keys) != len(r.Keys) {
+			return false
+		}
+		for i, key := range l.Keys {
+			if v, ok := r.Field(key); !ok || !equal(l.Values[i], v) {
+				return false
+			}
+		}
+		return true
+	case IntValue:
+		switch r := right.(type) {
+		case IntValue:
+			return l == r
+		case FloatValue:
+			return FloatValue(l) == r
+		}
+	case FloatValue:
+		switch r := right.(type) {
+		case IntValue:
+			return l == FloatValue(r)
+		case FloatValue:
+			return l == r
+		}
+	default:
+		return left == right
+	}
+	return false
+}
+
+// compare evaluates an ordering operator. Numbers compare by value and
+// strings lexicographically by byte.
+func compare(op Token, left, right Value) (Value, error) {
+	var c int
+	switch l := left.(type) {
+	case IntValue:
+		switch r := right.(type) {
+		case IntValue:
+			c = cmp.Compare(l, r)
+		case FloatValue:
+			c = cmp.Compare(FloatValue(l), r)
+		default:
+			return nil, unsupportedOperands(op, left, right)
+		}
+	case FloatValue:
+		switch r := right.(type) {
+		case IntValue:
+			c = cmp.Compare(l, FloatValue(r))
+		case FloatValue:
+			c = cmp.Compare(l, r)
+		default:
+			return nil, unsupportedOperands(op, left, right)
+		}
+	case StringValue:
+		r, ok := right.(StringValue)
+		if !ok {
+			return nil, unsupportedOperands(op, left, right)
+		}
+		c = strings.Compare(string(l), string(r))
+	default:
+		return nil, unsupportedOperands(op, left, right)
+	}
+	switch op.Type {
+	case Less:
+		return BoolValue(c < 0), nil
+	case LessEqual:
+		return BoolValue(c <= 0), nil
+	case Greater:
+		return BoolValue(c > 0), nil
+	default:
+		return BoolValue(c >= 0), nil
+	}
+}
+
+func unsupportedOperands(op Token, left, right Value) error {
+	return runtimeErrorf(op, "unsupported operand types for %s: %s and %s", op.Value, typeName(left), typeName(right))
+}
+
+func arithmetic(op Token, left, right Value) (Value, error) {
+	// % is only defined on ints, so check before mixed operands promote
+	if op.Type == Percent {
+		_, lok := left.(IntValue)
+		_, rok := right.(IntValue)
+		if !lok || !rok {
+			return nil, unsupportedOperands(op, left, right)
+		}
+	}
+	switch l := left.(type) {
+	case IntValue:
+		switch r := right.(type) {
+		case IntValue:
+			return intArithmetic(op, l, r)
+		case FloatValue:
+			return floatArithmetic(op, FloatValue(l), r)
+		}
+	case FloatValue:
+		switch r := right.(type) {
+		case IntValue:
+			return floatArithmetic(op, l, FloatValue(r))
+		case FloatValue:
+			return floatArithmetic(op, l, r)
+		}
+	case StringValue:
+		if r, ok := right.(StringValue); ok && op.Type == Plus {
+			return l + r, nil
+		}
+	}
+	return nil, unsupportedOperands(op, left, right)
+}
+
+func intArithmetic(op Token, l, r IntValue) (Value, error) {
+	switch op.Type {
+	case Plus:
+		return l + r, nil
+	case Minus:
+		return l - r, nil
+	case Asterisk:
+		return l * r, nil
+	case Slash:
+		return floatArithmetic(op, FloatValue(l), FloatValue(r))
+	case Percent:
+		if r == 0 {
+			return nil, runtimeErrorf(op, "modulo by zero")
+		}
+		return l % r, nil
+	case Power:
+		// a negative exponent takes the result out of the integers
+		if r < 0 {
+			return floatArithmetic(op, FloatValue(l), FloatValue(r))
+		}
+		return intPower(l, r), nil
+	}
+	return nil, runtimeErrorf(op, "unsupported operand types for %s: int and int", op.Value)
+}
+
+// intPower raises base to the non-negative exp by repeated squaring,
+// wrapping on overflow like the other integer operators.
+func intPower(base, exp IntValue) IntValue {
+	result := IntValue(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+// checkArithmetic reports an error if result, the value of left op right,
+// overflowed or is not a finite float.
+func checkArithmetic(op Token, left, right, result Value) error {
+	if f, ok := result.(FloatValue); ok && (math.IsInf(float64(f), 0) || math.IsNaN(float64(f))) {
+		return runtimeErrorf(op, "%s %s %s is %s", left, op.Value, right, f)
+	}
+	l, lok := left.(IntValue)
+	r, rok := right.(IntValue)
+	if !lok || !rok {
+		return nil
+	}
+	overflow := false
+	switch op.Type {
+	case Plus:
+		_, overflow = addInt(l, r)
+	case Minus:
+		// l - r overflows exactly when l + -r does, except that -r itself
+		// overflows for the smallest int
+		overflow = r == math.MinInt64 && l >= 0
+		if r != math.MinInt64 {
+			_, overflow = addInt(l, -r)
+		}
+	case Asterisk:
+		_, overflow = mulInt(l, r)
+	case Power:
+		_, overflow = checkedPower(l, r)
+	}
+	if overflow {
+		return runtimeErrorf(op, "integer overflow in %s %s %s", l, op.Value, r)
+	}
+	return nil
+}
+
+func addInt(a, b IntValue) (IntValue, bool) {
+	sum := a + b
+	// the sum wrapped if both operands have the same sign and it doesn't
+	return sum, (a >= 0) == (b >= 0) && (sum >= 0) != (a >= 0)
+}
+
+func mulInt(a, b IntValue) (IntValue, bool) {
+	if a == 0 || b == 0 {
+		return 0, false
+	}
+	product := a * b
+	return product, product/b != a || a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64
+}
+
+// checkedPower is intPower reporting whether the result overflowed. A
+// negative exponent gives a float, which cannot.
+func checkedPower(base, exp IntValue) (IntValue, bool) {
+	result := IntValue(1)
+	for exp > 0 {
+		var overflow bool
+		if exp&1 == 1 {
+			if result, overflow = mulInt(result, base); overflow {
+				return 0, true
+			}
+		}
+		exp >>= 1
+		if exp == 0 {
+			break
+		}
+		if base, overflow = mulInt(base, base); overflow {
+			return 0, true
+		}
+	}
+	return result, false
+}
+
+func floatArithmetic(op Token, l, r FloatValue) (Value, error) {
+	switch op.Type {
+	case Plus:
+		return l + r, nil
+	case Minus:
+		return l - r, nil
+	case Asterisk:
+		return l * r, nil
+	case Slash:
+		if r == 0 {
+			return nil, runtimeErrorf(op, "division by zero")
+		}
+		return l / r, nil
+	case Power:
+		return FloatValue(math.Pow(float64(l), float64(r))), nil
+	}
+	return nil, runtimeErrorf(op, "unsupported operand types for %s: float and float", op.Value)
+}
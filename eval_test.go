@@ -0,0 +1,238 @@
+package ged
+
+import "testing"
+
+// evalExpr parses src as an expression and evaluates it in a fresh
+// environment.
+func evalExpr(t *testing.T, src string) (Value, error) {
+	t.Helper()
+	return Eval(parseExpr(t, src), NewEnvironment())
+}
+
+func TestEval(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want Value
+	}{
+		{"1 + 2 * 3", IntValue(7)},
+		{"10 / 4", FloatValue(2.5)},
+		{"8 / 2", FloatValue(4)},
+		{"1 + 0.5", FloatValue(1.5)},
+		{"-(2 - 5)", IntValue(3)},
+		{"0x10 + 010", IntValue(26)},
+		{"1_000 * 2", IntValue(2000)},
+		{"2.5e1 - 5", FloatValue(20)},
+		{`"hi"`, StringValue("hi")},
+		{"-5 + 3", IntValue(-2)},
+		{"10 - 5", IntValue(5)},
+		{"- -5", IntValue(5)},
+		{"3 - -2", IntValue(5)},
+		{"-2.5 * 2", FloatValue(-5)},
+		{"true && false", BoolValue(false)},
+		{"false || true", BoolValue(true)},
+		{"false || true && false", BoolValue(false)},
+		{"false && (1 / 0)", BoolValue(false)},
+		{"true || undefined", BoolValue(true)},
+		{"true && true || 1 / 0", BoolValue(true)},
+		{"2 ** 3 ** 2", IntValue(512)},
+		{"(2 ** 3) ** 2", IntValue(64)},
+		{"2 * 3 ** 2", IntValue(18)},
+		{"2 ** 3", IntValue(8)},
+		{"2.0 ** 3", FloatValue(8)},
+		{"2 ** 0.5", FloatValue(1.4142135623730951)},
+		{"2 ** -1", FloatValue(0.5)},
+		{"3 ** 0", IntValue(1)},
+		{"2 ** 64", IntValue(0)},
+		{"3 < 5", BoolValue(true)},
+		{"5 <= 5", BoolValue(true)},
+		{"2.5 > 3", BoolValue(false)},
+		{"3 >= 2.5", BoolValue(true)},
+		{`"abc" < "abd"`, BoolValue(true)},
+		{"2 == 2.0", BoolValue(true)},
+		{"2 != 2.5", BoolValue(true)},
+		{`1 == "1"`, BoolValue(false)},
+		{`"a" != true`, BoolValue(true)},
+		{"true == true", BoolValue(true)},
+		{"!(1 < 2)", BoolValue(false)},
+		{"7 % 3", IntValue(1)},
+		{"-7 % 3", IntValue(-1)},
+		{"1 + 7 % 4 * 2", IntValue(7)},
+		{"if 1 < 2 then 10 else 1 / 0", IntValue(10)},
+		{"if 2 % 2 == 1 then 1 else 2.5", FloatValue(2.5)},
+		{`"a" + "b"`, StringValue("ab")},
+		{`"a" + "b" + "c"`, StringValue("abc")},
+		{`"" + ""`, StringValue("")},
+		{`'a' + "b" + '\n'`, StringValue("ab\n")},
+		{`'\u{e9}' == "é"`, BoolValue(true)},
+		{`"n=${1 + 2}" + "!"`, StringValue("n=3!")},
+		{"[[1, 2], [3, 4]][1][0]", IntValue(3)},
+		{"[1, 2] == [1, 2.0]", BoolValue(true)},
+		{"[1, 2] == [1]", BoolValue(false)},
+		{"[1] == 1", BoolValue(false)},
+		{"[10, 20][2 - 1]", IntValue(20)},
+		{"9223372036854775807 + 1", IntValue(-9223372036854775808)},
+		{"\"Hello, \\\n  ${1 + 1}\\\n\"", StringValue("Hello,   2")},
+		{"{a: 1, b: 2}.b", IntValue(2)},
+		{"{true: 1, if: 2, _: 3}.true", IntValue(1)},
+		{"{a: {b: [1, 2]}}.a.b[1]", IntValue(2)},
+		{"{a: 1, b: 2} == {b: 2, a: 1.0}", BoolValue(true)},
+		{"{a: 1} == {a: 1, b: 2}", BoolValue(false)},
+		{"{a: 1} == [1]", BoolValue(false)},
+		{"{} == {}", BoolValue(true)},
+	} {
+		got, err := evalExpr(t, tt.src)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q = %v (%s), want %v (%s)", tt.src, got, got.Type(), tt.want, tt.want.Type())
+		}
+	}
+}
+
+func TestEvalList(t *testing.T) {
+	got, err := evalExpr(t, `[1, "a", [true, 2.5]]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Type() != "list" || got.String() != "[1, a, [true, 2.5]]" {
+		t.Errorf("got %v (%s), want the list [1, a, [true, 2.5]]", got, got.Type())
+	}
+}
+
+func TestEvalRecord(t *testing.T) {
+	got, err := evalExpr(t, `{a: 1, b: "x", c: {d: [true]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Type() != "record" || got.String() != "{a: 1, b: x, c: {d: [true]}}" {
+		t.Errorf("got %v (%s), want the record {a: 1, b: x, c: {d: [true]}}", got, got.Type())
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"1 +\n 2 / 0", "runtime error at line 2, col 4: division by zero"},
+		{"1.5 / 0", "runtime error at line 1, col 5: division by zero"},
+		{`1 - "a"`, "runtime error at line 1, col 3: unsupported operand types for -: int and string"},
+		{"1 && true", "runtime error at line 1, col 3: && expects bool operands, got int"},
+		{`false || "a" || false`, "runtime error at line 1, col 7: || expects bool operands, got string"},
+		{"false || 2", "runtime error at line 1, col 7: || expects bool operands, got int"},
+		{"5 % 0", "runtime error at line 1, col 3: modulo by zero"},
+		{"5.0 % 2", "runtime error at line 1, col 5: unsupported operand types for %: float and int"},
+		{`1 < "a"`, "runtime error at line 1, col 3: unsupported operand types for <: int and string"},
+		{"true > false", "runtime error at line 1, col 6: unsupported operand types for >: bool and bool"},
+		{"if 1 then 2 else 3", "runtime error at line 1, col 1: if condition must be bool, got int"},
+		{"!1", "runtime error at line 1, col 1: unsupported operand type for !: int"},
+		{`"a" + 1`, "runtime error at line 1, col 5: unsupported operand types for +: string and int"},
+		{`1.5 + "a"`, "runtime error at line 1, col 5: unsupported operand types for +: float and string"},
+		{`"a" - "b"`, "runtime error at line 1, col 5: unsupported operand types for -: string and string"},
+		{`"a" * 2`, "runtime error at line 1, col 5: unsupported operand types for *: string and int"},
+		{"[1][1]", "runtime error at line 1, col 4: index 1 out of range for list of length 1"},
+		{"[1][-1]", "runtime error at line 1, col 4: index -1 out of range for list of length 1"},
+		{"[1][0.0]", "runtime error at line 1, col 4: list index must be int, got float"},
+		{"1[0]", "runtime error at line 1, col 2: cannot index int value 1"},
+		{"[][0]", "runtime error at line 1, col 3: index 0 out of range for list of length 0"},
+		{"{a: 1}.b", "runtime error at line 1, col 7: record has no field b"},
+		{"1 .a", "runtime error at line 1, col 3: cannot read field a of int value 1"},
+		{"[1].a", "runtime error at line 1, col 4: cannot read field a of list value [1]"},
+		{"{a: 1, a: 2}", "runtime error at line 1, col 1: duplicate field a in record"},
+		{"{a: { ; }}", "runtime error at line 1, col 1: field a has no value"},
+	} {
+		_, err := evalExpr(t, tt.src)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
+
+// runProgram parses src as a program and evaluates it in env.
+func runProgram(t *testing.T, src string, env *Environment) (Value, error) {
+	t.Helper()
+	return EvalProgram(parseProgram(t, src), env)
+}
+
+func TestEvalProgram(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want Value
+	}{
+		{"let a = 2; let b = a * 3; b + 1;", IntValue(7)},
+		{"let a = 1; let a = a + 1; a;", IntValue(2)},
+		{"let a = 1;", nil},
+		{"let base = 10; let addBase x = x + base; let base = 20; addBase 1;", IntValue(21)},
+		{"let mul a b = a * b; let sq x = mul x x; sq 7;", IntValue(49)},
+		{"let x = 1; let f x = x * 2; f 5 + x;", IntValue(11)},
+		{`let name = "world"; "Hi, ${name}!";`, StringValue("Hi, world!")},
+		{`let a = 1; let b = 2.5; "${a + b} and ${a}";`, StringValue("3.5 and 1")},
+		{`"cost: $5";`, StringValue("cost: $5")},
+		{"let x = 1; x = x + 41; x;", IntValue(42)},
+		{"let x = 1; x = 2;", nil},
+		{"let x = 10; x += 5; x -= 3; x *= 2 + 1; x;", IntValue(36)},
+		{"let x = 9; x /= 4; x;", FloatValue(2.25)},
+		{`let s = "a"; s += "b"; s;`, StringValue("ab")},
+		{"let x = 1; { x += 1; }; x;", IntValue(2)},
+		{"let число = 2; число * 3;", IntValue(6)},
+	} {
+		got, err := runProgram(t, tt.src, NewEnvironment())
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEvalProgramErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"let a = 1;\nc + 1;", "runtime error at line 2, col 1: undefined variable c"},
+		{"let b = b;", "runtime error at line 1, col 9: undefined variable b"},
+		{"let add a b = a + b;\nadd 1;", "runtime error at line 2, col 1: add expects 2 arguments, got 1"},
+		{"let add a b = a + b; add 1 2 3;", "runtime error at line 1, col 22: add expects 2 arguments, got 3"},
+		{"420 69;", "runtime error at line 1, col 1: cannot call int value 420"},
+		{"y = 2;", "runtime error at line 1, col 1: assignment to undefined variable y"},
+		{"let x = 1;\nx = z;", "runtime error at line 2, col 5: undefined variable z"},
+		{"z += 1;", "runtime error at line 1, col 1: undefined variable z"},
+	} {
+		_, err := runProgram(t, tt.src, NewEnvironment())
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestLetBindsInCurrentScope(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", IntValue(1))
+	inner := NewEnclosed(outer)
+	if got, err := runProgram(t, "let x = x + 1; x;", inner); err != nil || got != IntValue(2) {
+		t.Fatalf("got %v, %v, want 2", got, err)
+	}
+	if got, _ := outer.Get("x"); got != IntValue(1) {
+		t.Errorf("outer x = %v after binding x in an inner scope, want 1", got)
+	}
+}
+
+// TestEvalUnparsedLiteral checks literals built without the lexer, which
+// leave Parsed unset.
+func TestEvalUnparsedLiteral(t *testing.T) {
+	for _, tt := range []struct {
+		tok  Token
+		want Value
+	}{
+		{Token{Type: IntNumber, Value: "0x10"}, IntValue(16)},
+		{Token{Type: IntNumber, Value: "010"}, IntValue(10)},
+		{Token{Type: FloatNumber, Value: "2.5e1"}, FloatValue(25)},
+	} {
+		if got, err := Eval(&NumberLiteral{Token: tt.tok}, NewEnvironment()); err != nil || got != tt.want {
+			t.Errorf("%s: got %v, %v, want %v", tt.tok.Value, got, err, tt.want)
+		}
+	}
+}
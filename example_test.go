@@ -0,0 +1,27 @@
+package ged_test
+
+import (
+	"fmt"
+
+	ged "github.com/fedya-eremin/ged-compiler"
+)
+
+func ExampleNewLexer() {
+	tokens, err := ged.NewLexer("let x = 0x1F;\nprintln x").Tokenize()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, tok := range tokens {
+		fmt.Printf("%d:%d %q\n", tok.Line, tok.Column, tok.Value)
+	}
+	// Output:
+	// 1:1 "let"
+	// 1:5 "x"
+	// 1:7 "="
+	// 1:9 "0x1F"
+	// 1:13 ";"
+	// 2:1 "println"
+	// 2:9 "x"
+	// 2:10 ""
+}
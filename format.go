@@ -0,0 +1,349 @@
+package ged
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Binding strengths above the binary operators, used to decide where
+// Format needs parentheses.
+const (
+	unaryPrec = power + 1 + iota
+	applicationPrec
+	primaryPrec
+)
+
+const indentUnit = "    "
+
+// Format prints program as canonical source: one statement per line, single
+// spaces around binary operators and only the parentheses the grammar
+// needs. An if whose branches hold another if is broken over several lines
+// with its branches indented. Parsing the output gives back the same tree.
+func Format(program *Program) string {
+	var b strings.Builder
+	for _, st := range program.Statements {
+		formatStatement(&b, st, 0)
+		b.WriteString(";\n")
+	}
+	return b.String()
+}
+
+// formatStatement writes st without its semicolon.
+func formatStatement(b *strings.Builder, st Statement, depth int) {
+	switch s := st.(type) {
+	case *LetStatement:
+		b.WriteString("let " + s.Name + " = ")
+		formatExpr(b, s.Value, lowest, depth)
+	case *FunctionDef:
+		b.WriteString("let " + s.Name)
+		for _, param := range s.Params {
+			b.WriteString(" " + param)
+		}
+		b.WriteString(" = ")
+		formatExpr(b, s.Body, lowest, depth)
+	case *AssignStatement:
+		b.WriteString(s.Name + " = ")
+		formatExpr(b, s.Value, lowest, depth)
+	case *ExpressionStatement:
+		formatExpr(b, s.Expr, lowest, depth)
+	default:
+		b.WriteString(st.String())
+	}
+}
+
+// formatExpr writes e, parenthesized if it binds looser than minPrec.
+// depth is the indentation level of the line e starts on.
+func formatExpr(b *strings.Builder, e Expr, minPrec, depth int) {
+	if exprPrec(e) < minPrec {
+		b.WriteByte('(')
+		formatExpr(b, e, lowest, depth)
+		b.WriteByte(')')
+		return
+	}
+	switch n := e.(type) {
+	case *BinaryExpr:
+		left, right := precedences[n.Op.Type], precedences[n.Op.Type]+1
+		if rightAssociative[n.Op.Type] {
+			left, right = right, left
+		}
+		formatExpr(b, n.Left, left, depth)
+		b.WriteString(" " + n.Op.Value + " ")
+		formatExpr(b, n.Right, right, depth)
+	case *UnaryExpr:
+		b.WriteString(n.Op.Value)
+		// keep - -x from reading as a decrement
+		if inner, ok := n.Operand.(*UnaryExpr); ok && inner.Op.Type == n.Op.Type {
+			b.WriteByte(' ')
+		}
+		formatExpr(b, n.Operand, unaryPrec, depth)
+	case *CallExpr:
+		formatExpr(b, n.Callee, primaryPrec, depth)
+		for _, arg := range n.Args {
+			b.WriteByte(' ')
+			// a bracket after the callee would index it
+			if startsWithBracket(arg) {
+				b.WriteByte('(')
+				formatExpr(b, arg, lowest, depth)
+				b.WriteByte(')')
+				continue
+			}
+			formatExpr(b, arg, primaryPrec, depth)
+		}
+	case *MemberExpr:
+		formatObject(b, n.Object, depth)
+		b.WriteString("." + n.Name)
+	case *IndexExpr:
+		formatObject(b, n.Object, depth)
+		b.WriteByte('[')
+		formatExpr(b, n.Index, lowest, depth)
+		b.WriteByte(']')
+	case *ListExpr:
+		b.WriteByte('[')
+		for i, e := range n.Elements {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			formatElement(b, e, i == len(n.Elements)-1, depth)
+		}
+		b.WriteByte(']')
+	case *RecordLiteral:
+		b.WriteByte('{')
+		for i, key := range n.Keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(key + ": ")
+			formatElement(b, n.Values[i], i == len(n.Keys)-1, depth)
+		}
+		b.WriteByte('}')
+	case *BlockExpr:
+		// an empty block keeps a semicolon, as {} is an empty record
+		if len(n.Statements) == 0 && n.Result == nil {
+			b.WriteString("{ ; }")
+			return
+		}
+		// a block of just a result stays on one line
+		if len(n.Statements) == 0 && n.Result != nil {
+			b.WriteString("{ ")
+			formatExpr(b, n.Result, lowest, depth)
+			b.WriteString(" }")
+			return
+		}
+		b.WriteByte('{')
+		for _, st := range n.Statements {
+			newline(b, depth+1)
+			formatStatement(b, st, depth+1)
+			b.WriteByte(';')
+		}
+		if n.Result != nil {
+			newline(b, depth+1)
+			formatExpr(b, n.Result, lowest, depth+1)
+		}
+		newline(b, depth)
+		b.WriteByte('}')
+	case *LetInExpr:
+		b.WriteString("let " + n.Name + " = ")
+		formatExpr(b, n.Value, lowest, depth)
+		b.WriteString(" in ")
+		formatExpr(b, n.Body, lowest, depth)
+	case *WhereExpr:
+		// an if or let head would take the where into its last part
+		formatExpr(b, n.Expr, pipe, depth)
+		b.WriteString(" where ")
+		for i, name := range n.Names {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(name + " = ")
+			formatElement(b, n.Values[i], i == len(n.Names)-1, depth)
+		}
+	case *IfExpr:
+		formatIf(b, n, depth, false)
+	case *InterpStringExpr:
+		b.WriteByte('"')
+		for i, part := range n.Parts {
+			if i%2 == 0 {
+				b.WriteString(quote(part.(*StringLiteral).Token.Value))
+				continue
+			}
+			b.WriteString("${")
+			formatExpr(b, part, lowest, depth)
+			b.WriteByte('}')
+		}
+		b.WriteByte('"')
+	case *NumberLiteral:
+		// folded constants may be negative, which is no literal
+		if s, err := NormalizeNumber(n.Token.Value); err == nil {
+			b.WriteString(s)
+		} else {
+			b.WriteString(n.Token.Value)
+		}
+	case *Ident:
+		b.WriteString(n.Name)
+	default:
+		b.WriteString(e.String())
+	}
+}
+
+// NormalizeNumber returns the canonical spelling of a number literal:
+// without digit separators or redundant leading zeros, with hex digits in
+// lower case, with a lower-case e and digits on both sides of the decimal
+// point, so 007 is 7, 0x00FF is 0xff, 5. is 5.0, .5 is 0.5 and 1E05 is
+// 1e5. Anything that is not a single number literal is an error.
+func NormalizeNumber(lexeme string) (string, error) {
+	tokens, err := NewLexer(lexeme).Tokenize()
+	if err != nil {
+		return "", err
+	}
+	if len(tokens) != 2 || tokens[0].Type != IntNumber && tokens[0].Type != FloatNumber || tokens[0].End-tokens[0].Offset != len(lexeme) {
+		return "", fmt.Errorf("%q is not a number literal", lexeme)
+	}
+	tok := tokens[0]
+	var s string
+	switch value := tok.Value; {
+	case tok.Type == IntNumber && len(value) > 1 && strings.ContainsRune("xob", rune(value[1])):
+		s = value[:2] + trimZeros(strings.ToLower(value[2:]))
+	case tok.Type == IntNumber:
+		s = trimZeros(value)
+	default:
+		mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(value), "e")
+		whole, fraction, hasPoint := strings.Cut(mantissa, ".")
+		s = trimZeros(whole)
+		if hasPoint {
+			if fraction == "" {
+				fraction = "0"
+			}
+			s += "." + fraction
+		}
+		if hasExponent {
+			sign := ""
+			if exponent[0] == '+' || exponent[0] == '-' {
+				sign, exponent = exponent[:1], exponent[1:]
+			}
+			s += "e" + sign + trimZeros(exponent)
+		}
+	}
+	// the canonical form must lex back to the same number
+	again, err := NewLexer(s).Tokenize()
+	if err != nil || len(again) != 2 || again[0].Type != tok.Type || again[0].Parsed != tok.Parsed {
+		return "", fmt.Errorf("normalizing %q gave %q, which reads back differently", lexeme, s)
+	}
+	return s, nil
+}
+
+// trimZeros strips the leading zeros of digits, keeping one if that is all
+// there is.
+func trimZeros(digits string) string {
+	if trimmed := strings.TrimLeft(digits, "0"); trimmed != "" {
+		return trimmed
+	}
+	return "0"
+}
+
+// formatIf writes n on one line unless a branch is itself an if, or broken
+// is set by an enclosing else-if chain.
+func formatIf(b *strings.Builder, n *IfExpr, depth int, broken bool) {
+	_, thenIf := n.Then.(*IfExpr)
+	elseIf, chained := n.Else.(*IfExpr)
+	if !broken && !thenIf && !chained {
+		b.WriteString("if ")
+		formatExpr(b, n.Cond, lowest, depth)
+		b.WriteString(" then ")
+		formatExpr(b, n.Then, lowest, depth)
+		b.WriteString(" else ")
+		formatExpr(b, n.Else, lowest, depth)
+		return
+	}
+	b.WriteString("if ")
+	formatExpr(b, n.Cond, lowest, depth)
+	b.WriteString(" then")
+	newline(b, depth+1)
+	formatExpr(b, n.Then, lowest, depth+1)
+	newline(b, depth)
+	b.WriteString("else")
+	if chained {
+		b.WriteByte(' ')
+		formatIf(b, elseIf, depth, true)
+		return
+	}
+	newline(b, depth+1)
+	formatExpr(b, n.Else, lowest, depth+1)
+}
+
+// formatElement writes e as an item of a comma-separated list, which a
+// where ending an item before the last would run on into.
+func formatElement(b *strings.Builder, e Expr, last bool, depth int) {
+	if !last && endsWithWhere(e) {
+		b.WriteByte('(')
+		formatExpr(b, e, lowest, depth)
+		b.WriteByte(')')
+		return
+	}
+	formatExpr(b, e, lowest, depth)
+}
+
+// endsWithWhere reports whether e is written ending in a where clause.
+func endsWithWhere(e Expr) bool {
+	switch n := e.(type) {
+	case *WhereExpr:
+		return true
+	case *IfExpr:
+		return endsWithWhere(n.Else)
+	case *LetInExpr:
+		return endsWithWhere(n.Body)
+	}
+	return false
+}
+
+// formatObject writes the object of a member access or index. A number is
+// parenthesized, as in 0.x the dot would read back as a decimal point.
+func formatObject(b *strings.Builder, object Expr, depth int) {
+	if _, ok := object.(*NumberLiteral); ok {
+		b.WriteByte('(')
+		formatExpr(b, object, lowest, depth)
+		b.WriteByte(')')
+		return
+	}
+	formatExpr(b, object, primaryPrec, depth)
+}
+
+// startsWithBracket reports whether e is written starting with a list
+// literal. A member access or index on a number starts with the
+// parenthesis formatObject puts around it instead.
+func startsWithBracket(e Expr) bool {
+	switch n := e.(type) {
+	case *ListExpr:
+		return true
+	case *IndexExpr:
+		return startsWithBracket(n.Object)
+	case *MemberExpr:
+		return startsWithBracket(n.Object)
+	}
+	return false
+}
+
+func newline(b *strings.Builder, depth int) {
+	b.WriteByte('\n')
+	b.WriteString(strings.Repeat(indentUnit, depth))
+}
+
+func exprPrec(e Expr) int {
+	switch n := e.(type) {
+	case *BinaryExpr:
+		return precedences[n.Op.Type]
+	case *UnaryExpr:
+		return unaryPrec
+	case *CallExpr:
+		return applicationPrec
+	case *IfExpr, *LetInExpr, *WhereExpr:
+		return lowest
+	case *NumberLiteral:
+		// folded constants can be negative, which reads back as a negation
+		if strings.HasPrefix(n.Token.Value, "-") {
+			return unaryPrec
+		}
+		return primaryPrec
+	default:
+		return primaryPrec
+	}
+}
@@ -0,0 +1,147 @@
+package ged
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{runSample, "println (420 + 69);\nlet sayHello name = printf \"Hi, %s!\\n\" name;\nsayHello \"world\";\n"},
+		{"let  f x y=if x<y then if x == 0 then -y else x   else if y>0 then y*(x+1) else - -x;",
+			"let f x y = if x < y then\n    if x == 0 then -y else x\nelse if y > 0 then\n    y * (x + 1)\nelse\n    - -x;\n"},
+		{`(f 1) 2; f (-1) (g 2) "a${x+1}\${b}" ;`, "(f 1) 2;\nf (-1) (g 2) \"a${x + 1}\\${b}\";\n"},
+		{"let w = !(a == b) ;  h (if a then 1 else 2);", "let w = !(a == b);\nh (if a then 1 else 2);\n"},
+		{"let x=1; x=x+1;", "let x = 1;\nx = x + 1;\n"},
+		{"f (0 - 5) (-2);", "f (0 - 5) (-2);\n"},
+		{"let r = {let: 1,then: r.if}; f r.x {a: (g y).b};", "let r = {let: 1, then: r.if};\nf r.x {a: (g y).b};\n"},
+		{"let y = { let x = 2 * 3; x = x + 1; x };\nlet z = { 1 };\n", "let y = {\n    let x = 2 * 3;\n    x = x + 1;\n    x\n};\nlet z = { 1 };\n"},
+		{"let x = 2 ** 3 ** 2; let y = (2 ** 3) ** 2; let z = 2 * 3 ** 2;", "let x = 2 ** 3 ** 2;\nlet y = (2 ** 3) ** 2;\nlet z = 2 * 3 ** 2;\n"},
+		{"let xs = [1, 2 * 3, [4]];\nlet y = (f xs)[0] + xs[2][0];\nlet z = f ([1]) ([2][0]) ([3].n) xs[0];\n", "let xs = [1, 2 * 3, [4]];\nlet y = (f xs)[0] + xs[2][0];\nlet z = f ([1]) ([2][0]) ([3].n) xs[0];\n"},
+		{`let s = "a\u{7}b\u{200b}\x41\t";`, "let s = \"a\\u{7}b\\u{200b}A\\t\";\n"},
+		{"let y = let x = 2 * 3 in x + 1;\nlet z = (let a = 1 in a) * 2;\nlet in_ = r.in;\n", "let y = let x = 2 * 3 in x + 1;\nlet z = (let a = 1 in a) * 2;\nlet in_ = r.in;\n"},
+		{"let _ = f 1;\nlet g _ y = y;\n", "let _ = f 1;\nlet g _ y = y;\n"},
+		{"let y = (if c then a else b where b = 1) + 2;\nlet w = (if c then a else b) where a = 1, b = (c where c = 2), d = 3;\nlet v = {p: (a where a = 1), q: a where a = 2};\nf (a where a = 1);\n", "let y = (if c then a else b where b = 1) + 2;\nlet w = (if c then a else b) where a = 1, b = (c where c = 2), d = 3;\nlet v = {p: (a where a = 1), q: a where a = 2};\nf (a where a = 1);\n"},
+		{"x *= a + b;", "x = x * (a + b);\n"},
+		{"let x = 007 + .5 * 0xFF + 5. ** 1E05;", "let x = 7 + 0.5 * 0xff + 5.0 ** 1e5;\n"},
+		{"let e = { ; }; let r = {};", "let e = { ; };\nlet r = {};\n"},
+		{"0 .A; let i = 7[0] + (0).a[1];", "(0).A;\nlet i = (7)[0] + (0).a[1];\n"},
+		{"let r = {true: 1, _: 2}; f r.true r._;", "let r = {true: 1, _: 2};\nf r.true r._;\n"},
+	} {
+		if got := Format(parseProgram(t, tt.src)); got != tt.want {
+			t.Errorf("%q: formatted as\n%s\nwant\n%s", tt.src, got, tt.want)
+		}
+	}
+}
+
+// TestFormatRoundTrip checks that formatting a program and parsing the
+// output gives back the same tree, and that formatting again changes
+// nothing.
+func TestFormatRoundTrip(t *testing.T) {
+	for _, src := range []string{
+		sample,
+		runSample,
+		"let x = 1 + 2 * 3;",
+		"let z = (if a then b else c) + 1 * (2 - 3) - (4 - 5);",
+		"f (-1) (g 2) \"a${x+1}\\${b}\";",
+		"let f x y = if x < y then if x == 0 then -y else x else - -x;",
+		"let y = { let x = 2 * 3; x = x + 1; x }; f { g 1; };",
+		"let s = \"a\\u{7}b\\u{200b}é\\\"\\\\ $ \\${x}\\x41\";",
+		"let z = [(a where a = 1), (if c then 1 else b where b = 2), 3] where c = true;",
+		"f (g x) ([1, 2]) {a: 1};",
+		"let b = { 1 };",
+		"let e = { ; };",
+		"let e = {};",
+		`let c = f '\'' '"' 'x' '\n';`,
+		"x |> f a |> g;",
+		"let w = x * y where x = 2, y = x + 1;",
+		"0 .A;",
+		"1.5 .x.y;",
+		"-1 .x;",
+		"f 2 .x ([3]) 4[0];",
+		"let i = 7[0] + (0).a[1];",
+	} {
+		program := parseProgram(t, src)
+		out := Format(program)
+		reparsed, err := NewParser(NewLexer(out)).ParseProgram()
+		if err != nil {
+			t.Errorf("%s: formatted as %q, which does not parse: %v", src, out, err)
+			continue
+		}
+		if reparsed.String() != program.String() || shape(reparsed) != shape(program) {
+			t.Errorf("%s: formatted as %q, which parses as %s, want %s", src, out, reparsed, program)
+		}
+		if again := Format(reparsed); again != out {
+			t.Errorf("%s: formatted as %q, then as %q", src, out, again)
+		}
+	}
+}
+
+// shape lists the types of the nodes in program, which String alone does
+// not show: an empty block and an empty record both print as {}. They are
+// sorted, so that only which nodes there are counts and not the order Walk
+// visits them in.
+func shape(program *Program) string {
+	var types []string
+	for _, st := range program.Statements {
+		Walk(st, func(n Node) bool {
+			types = append(types, fmt.Sprintf("%T", n))
+			return true
+		})
+	}
+	slices.Sort(types)
+	return strings.Join(types, " ")
+}
+
+func TestFormatFoldedNegative(t *testing.T) {
+	folded, err := Fold(parseExpr(t, "f (0 - 5) (1 - 2 * 3)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := &Program{Statements: []Statement{&ExpressionStatement{Expr: folded}}}
+	if got, want := Format(program), "f (-5) (-5);\n"; got != want {
+		t.Errorf("formatted as %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	for _, tt := range []struct {
+		lexeme, want string
+	}{
+		{"007", "7"},
+		{"0", "0"},
+		{"000", "0"},
+		{"42", "42"},
+		{"1_000", "1000"},
+		{"5.", "5.0"},
+		{".5", "0.5"},
+		{"00.50", "0.50"},
+		{"0.0", "0.0"},
+		{"0xFF", "0xff"},
+		{"0x00aB", "0xab"},
+		{"0x0", "0x0"},
+		{"0b0011", "0b11"},
+		{"0o017", "0o17"},
+		{"1E05", "1e5"},
+		{"2.e-03", "2.0e-3"},
+		{"1e+0", "1e+0"},
+		{".5E1", "0.5e1"},
+		{"3e10", "3e10"},
+	} {
+		if got, err := NormalizeNumber(tt.lexeme); err != nil || got != tt.want {
+			t.Errorf("%q: got %q, %v, want %q", tt.lexeme, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeNumberErrors(t *testing.T) {
+	for _, lexeme := range []string{"", "x", "1 2", "-1", "1.2.3", "0x", "1e", "99999999999999999999", "0xZZ", "1+1", " 1"} {
+		if got, err := NormalizeNumber(lexeme); err == nil {
+			t.Errorf("%q: normalized to %q, want an error", lexeme, got)
+		}
+	}
+}
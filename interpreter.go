@@ -0,0 +1,111 @@
+package ged
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+)
+
+// Interpreter evaluates programs against a persistent global environment,
+// so bindings from one Run are visible to the next.
+type Interpreter struct {
+	// Out receives everything the built-ins print. It defaults to
+	// os.Stdout and may be replaced at any time.
+	Out io.Writer
+	// CheckedArithmetic makes +, -, * and ** on ints fail with a runtime
+	// error when the result overflows, rather than wrapping around, and any
+	// arithmetic giving an infinite or NaN float fail too.
+	CheckedArithmetic bool
+	// ForceOverride lets RegisterBuiltin replace the standard built-ins
+	// such as println.
+	ForceOverride bool
+	env           *Environment
+}
+
+func NewInterpreter() *Interpreter {
+	in := &Interpreter{Out: os.Stdout}
+	in.env = globals(in.output)
+	return in
+}
+
+func (in *Interpreter) output() io.Writer {
+	if in.Out == nil {
+		return os.Stdout
+	}
+	return in.Out
+}
+
+// Env returns the interpreter's global environment.
+func (in *Interpreter) Env() *Environment {
+	return in.env
+}
+
+// RegisterBuiltin adds a function implemented in Go to the global
+// environment, where programs run afterwards can call it as name. It fails
+// if name is not a valid identifier, or is a standard built-in and
+// ForceOverride is not set. Registering a name again replaces the function.
+// Programs using it should be compiled with the interpreter's Compile or
+// CompileFile, which know about it, rather than the package's.
+func (in *Interpreter) RegisterBuiltin(name string, fn func(args []Value) (Value, error)) error {
+	tokens, err := NewLexer(name).Tokenize()
+	if err != nil || len(tokens) != 2 || tokens[0].Type != Identifier {
+		return fmt.Errorf("cannot register built-in %q: not an identifier", name)
+	}
+	if fn == nil {
+		return fmt.Errorf("cannot register built-in %s: nil function", name)
+	}
+	reserved := slices.ContainsFunc(builtins(nil), func(b *BuiltinValue) bool { return b.Name == name })
+	if reserved && !in.ForceOverride {
+		return fmt.Errorf("cannot register built-in %s: it would replace the standard one", name)
+	}
+	in.env.Set(name, &BuiltinValue{Name: name, Fn: fn})
+	return nil
+}
+
+// Compile is CompileFile for source held in memory, with problems attributed
+// to no file.
+func (in *Interpreter) Compile(source string) (*Program, error) {
+	return compile(source, "", in.globalNames()...)
+}
+
+// CompileFile is like the package's CompileFile, except that names bound in
+// the interpreter's global environment, such as registered built-ins and
+// the bindings of earlier runs, are not reported as undefined.
+func (in *Interpreter) CompileFile(path string) (*Program, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return compile(string(src), path, in.globalNames()...)
+}
+
+func (in *Interpreter) globalNames() []string {
+	return slices.Collect(maps.Keys(in.env.store))
+}
+
+// Run evaluates program and returns the value of its last statement.
+func (in *Interpreter) Run(program *Program) (Value, error) {
+	in.env.checked = in.CheckedArithmetic
+	return EvalProgram(program, in.env)
+}
+
+// Run lexes, parses, resolves and evaluates source, printing to os.Stdout.
+// Errors name the phase that failed and the position in source; several
+// problems found before running are returned together as CompileErrors.
+func Run(source string) error {
+	return RunWithOutput(source, os.Stdout)
+}
+
+// RunWithOutput is like Run but prints to out.
+func RunWithOutput(source string, out io.Writer) error {
+	program, err := compile(source, "")
+	if err != nil {
+		return err
+	}
+	in := NewInterpreter()
+	in.Out = out
+	_, err = in.Run(program)
+	return err
+}
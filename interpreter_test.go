@@ -0,0 +1,368 @@
+package ged
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestInterpreterOutput(t *testing.T) {
+	in := NewInterpreter()
+	var out bytes.Buffer
+	in.Out = &out
+	for _, src := range []string{`let x = 2; println "x is" x;`, `println (x * 2);`} {
+		if _, err := in.Run(parseProgram(t, src)); err != nil {
+			t.Fatalf("%q: %v", src, err)
+		}
+	}
+	if want := "x is 2\n4\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", &out, want)
+	}
+
+	var other bytes.Buffer
+	in.Out = &other
+	if _, err := in.Run(parseProgram(t, `println x;`)); err != nil {
+		t.Fatal(err)
+	}
+	if other.String() != "2\n" || out.String() != "x is 2\n4\n" {
+		t.Errorf("after replacing Out, printed %q and %q, want only %q in the new writer", &out, &other, "2\n")
+	}
+}
+
+// runSample is the program cmd/ged runs when given no source.
+const runSample = `
+			println (420 + 69);
+			let sayHello name = printf "Hi, %s!\n" name;
+			sayHello "world";
+		`
+
+func TestRunWithOutput(t *testing.T) {
+	var out bytes.Buffer
+	if err := RunWithOutput(runSample, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "489\nHi, world!\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", &out, want)
+	}
+}
+
+func TestRunWithOutputErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"let x = @;", "lex error at line 1, col 9: unknown token '@'"},
+		{"let x = ;", `parse error at line 1, col 9: expected an expression, found ";"`},
+		{"let x = y;", "resolve error at line 1, col 9: undefined variable y"},
+		{"let x = y; z;", "resolve error at line 1, col 9: undefined variable y\nresolve error at line 1, col 12: undefined variable z"},
+		{"1 / 0;", "runtime error at line 1, col 3: division by zero"},
+		{"let z = { 1; }; println z;", "runtime error at line 1, col 17: argument z to println has no value"},
+		{"let f n = if n then 1 else 2; f 1;", "runtime error at line 1, col 11: if condition must be bool, got int"},
+		{"println (y where y = x, x = 1);", "resolve error at line 1, col 22: undefined variable x"},
+		{"println (x where x = x);", "resolve error at line 1, col 22: undefined variable x"},
+		{"println (a where a = 1); println a;", "resolve error at line 1, col 34: undefined variable a"},
+		{"y += 1;", "resolve error at line 1, col 1: assignment to undefined variable y"},
+	} {
+		var out bytes.Buffer
+		if err := RunWithOutput(tt.src, &out); err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
+
+// TestShortCircuit checks that the right operand of && and || runs only
+// when the left one does not decide the result.
+func TestShortCircuit(t *testing.T) {
+	for _, tt := range []struct {
+		src, want, err string
+	}{
+		{`true || println "no";`, "", ""},
+		{`false && println "no";`, "", ""},
+		{`false || println "yes";`, "yes\n", "runtime error at line 1, col 7: || expects bool operands, got nothing"},
+		{`true && println "yes";`, "yes\n", "runtime error at line 1, col 6: && expects bool operands, got nothing"},
+	} {
+		var out bytes.Buffer
+		err := RunWithOutput(tt.src, &out)
+		if (err == nil) != (tt.err == "") || err != nil && err.Error() != tt.err {
+			t.Errorf("%q: got error %v, want %q", tt.src, err, tt.err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q: printed %q, want %q", tt.src, &out, tt.want)
+		}
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"let x = 1; let y = { let x = 10; x * 2 }; println (x + y);", "21\n"},
+		{"let x = 1; { let x = 10; x }; println x;", "1\n"},
+		{"let x = 1; { x = 5; }; println x;", "5\n"},
+		{"let f n = { let d = n * 2; d + 1 }; println (f 3);", "7\n"},
+	} {
+		var out bytes.Buffer
+		if err := RunWithOutput(tt.src, &out); err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q: printed %q, want %q", tt.src, &out, tt.want)
+		}
+	}
+}
+
+// TestTailCalls runs recursion a million calls deep, which overflows the Go
+// stack unless calls in tail position reuse the caller's frame.
+func TestTailCalls(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"let count n acc = if n == 0 then acc else count (n - 1) (acc + 1); println (count 1000000 0);", "1000000\n"},
+		{"let even n = if n == 0 then true else odd (n - 1);\nlet odd n = if n == 0 then false else { let m = n - 1; even m };\nprintln (even 1000001);", "false\n"},
+		{"let fact n = if n < 2 then 1 else n * fact (n - 1); println (fact 20);", "2432902008176640000\n"},
+	} {
+		var out bytes.Buffer
+		if err := RunWithOutput(tt.src, &out); err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q: printed %q, want %q", tt.src, &out, tt.want)
+		}
+	}
+}
+
+func TestLetIn(t *testing.T) {
+	src := `
+let x = 100;
+println (let x = 5 in x + 1);
+println (let a = 1 in let b = a + 10 in let a = b * 2 in a + b);
+println x;
+let y = let x = 2 in x * x;
+println y;
+let r = { let q = 3 in q * 2 };
+println r;
+let loop n = let m = n - 1 in if m == 0 then "done" else loop m;
+println (loop 100000);
+let z = 1 in println z;
+`
+	var out bytes.Buffer
+	if err := RunWithOutput(src, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "6\n33\n100\n4\n6\ndone\n1\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", &out, want)
+	}
+}
+
+func TestWildcardBindings(t *testing.T) {
+	src := `
+let _ = println "side";
+let k a _ = a;
+println (k 1 2);
+let _ = 3;
+let v = let _ = 5 in 6;
+println v;
+`
+	var out bytes.Buffer
+	if err := RunWithOutput(src, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "side\n1\n6\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", &out, want)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	src := `
+let double n = n * 2;
+let add a b = a + b;
+let inc n = n + 1;
+println ((5 |> double) == double 5);
+println (1 |> inc |> double);
+println (3 |> add 4 |> double);
+`
+	var out bytes.Buffer
+	if err := RunWithOutput(src, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "true\n4\n14\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", &out, want)
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	run := func(src string, checked bool) (string, error) {
+		in := NewInterpreter()
+		var out bytes.Buffer
+		in.Out = &out
+		in.CheckedArithmetic = checked
+		_, err := in.Run(parseProgram(t, src))
+		return out.String(), err
+	}
+	for _, tt := range []struct {
+		src, wrapped, want string
+	}{
+		{"println (9223372036854775807 * 2);", "-2\n", "runtime error at line 1, col 30: integer overflow in 9223372036854775807 * 2"},
+		{"println (9223372036854775807 + 1);", "-9223372036854775808\n", "runtime error at line 1, col 30: integer overflow in 9223372036854775807 + 1"},
+		{"let m = -9223372036854775807 - 1;\nprintln (m - 1);", "9223372036854775807\n", "runtime error at line 2, col 12: integer overflow in -9223372036854775808 - 1"},
+		{"let m = -9223372036854775807 - 1; println (-m);", "-9223372036854775808\n", "runtime error at line 1, col 44: integer overflow in -(-9223372036854775808)"},
+		{"println (2 ** 63);", "-9223372036854775808\n", "runtime error at line 1, col 12: integer overflow in 2 ** 63"},
+		{"println (1e308 * 10);", "+Inf\n", "runtime error at line 1, col 16: 1e+308 * 10 is +Inf"},
+		{"println (1e308 ** 2);", "+Inf\n", "runtime error at line 1, col 16: 1e+308 ** 2 is +Inf"},
+	} {
+		if out, err := run(tt.src, false); err != nil || out != tt.wrapped {
+			t.Errorf("%q unchecked: printed %q, %v, want %q", tt.src, out, err, tt.wrapped)
+		}
+		if _, err := run(tt.src, true); err == nil || err.Error() != tt.want {
+			t.Errorf("%q checked: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+
+	// results that only just fit are not overflows
+	src := "let m = -9223372036854775807 - 1; println ([m + 0, m * 1, 2 ** 62, (-2) ** 63, m - -1, 3037000499 * 3037000499, 0 * m, 1.5 * 2, 2 ** -1]);"
+	out, err := run(src, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[-9223372036854775808, -9223372036854775808, 4611686018427387904, -9223372036854775808, -9223372036854775807, 9223372030926249001, 0, 3, 0.5]\n"; out != want {
+		t.Errorf("printed %q, want %q", out, want)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	in := NewInterpreter()
+	var out bytes.Buffer
+	in.Out = &out
+	err := in.RegisterBuiltin("double", func(args []Value) (Value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expects 1 argument, got %d", len(args))
+		}
+		n, ok := args[0].(IntValue)
+		if !ok {
+			return nil, fmt.Errorf("expects an int, got %s", args[0].Type())
+		}
+		return n * 2, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Run(parseProgram(t, "println (double 21);\nprintln (5 |> double |> double);")); err != nil {
+		t.Fatal(err)
+	}
+	if want := "42\n20\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", &out, want)
+	}
+	_, err = in.Run(parseProgram(t, `double "x";`))
+	if want := "runtime error at line 1, col 1: double: expects an int, got string"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestRegisterBuiltinErrors(t *testing.T) {
+	fn := func([]Value) (Value, error) { return StringValue("hi"), nil }
+	for _, tt := range []struct {
+		name string
+		fn   func([]Value) (Value, error)
+		want string
+	}{
+		{"println", fn, "cannot register built-in println: it would replace the standard one"},
+		{"let", fn, `cannot register built-in "let": not an identifier`},
+		{"a b", fn, `cannot register built-in "a b": not an identifier`},
+		{"", fn, `cannot register built-in "": not an identifier`},
+		{"1x", fn, `cannot register built-in "1x": not an identifier`},
+		{"_", fn, `cannot register built-in "_": not an identifier`},
+		{"double", nil, "cannot register built-in double: nil function"},
+	} {
+		if err := NewInterpreter().RegisterBuiltin(tt.name, tt.fn); err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.name, err, tt.want)
+		}
+	}
+
+	in := NewInterpreter()
+	in.ForceOverride = true
+	if err := in.RegisterBuiltin("println", fn); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := in.Run(parseProgram(t, "println 1;")); err != nil || v != StringValue("hi") {
+		t.Errorf("got %v, %v from the replaced println, want hi", v, err)
+	}
+}
+
+func TestWhere(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"println (x * 2 where x = 21);", "42\n"},
+		{"println (x * y where x = 2, y = x + 1);", "6\n"},
+		{"let f n = a + b where a = n, b = a * 10; println (f 3);", "33\n"},
+		{"let x = 100; println (x where x = 1); println x;", "1\n100\n"},
+		{"println ([a where a = 1, b = 2]);", "[1]\n"},
+		{"println ([(a where a = 1), 2]);", "[1, 2]\n"},
+		{"let loop n acc = if n == 0 then acc else loop m (acc + 1) where m = n - 1; println (loop 100000 0);", "100000\n"},
+	} {
+		var out bytes.Buffer
+		if err := RunWithOutput(tt.src, &out); err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q: printed %q, want %q", tt.src, &out, tt.want)
+		}
+	}
+}
+
+// TestRunDiscardsExpressionValues checks that Run, unlike the REPL, prints
+// nothing for an expression statement.
+func TestRunDiscardsExpressionValues(t *testing.T) {
+	var out bytes.Buffer
+	if err := RunWithOutput("1 + 2;\nlet x = 4;\nx;\nprintln x;\n", &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "4\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", &out, want)
+	}
+}
+
+// TestInterpreterCompile checks that compiling for an interpreter resolves
+// its registered built-ins and the bindings of earlier runs.
+func TestInterpreterCompile(t *testing.T) {
+	in := NewInterpreter()
+	var out bytes.Buffer
+	in.Out = &out
+	err := in.RegisterBuiltin("double", func(args []Value) (Value, error) {
+		return args[0].(IntValue) * 2, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeFile(t, "double.ged", "println (double 21);")
+	if _, err := CompileFile(path); err == nil {
+		t.Error("the package's CompileFile resolved a name only the interpreter binds")
+	}
+	program, err := in.CompileFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Run(program); err != nil {
+		t.Fatal(err)
+	}
+	if want := "42\n"; out.String() != want {
+		t.Errorf("printed %q, want %q", &out, want)
+	}
+
+	program, err = in.Compile("let x = 1;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Run(program); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Compile("println (double x);"); err != nil {
+		t.Errorf("x bound by an earlier run: %v", err)
+	}
+	_, err = in.Compile("println y;")
+	if want := "resolve error at line 1, col 9: undefined variable y"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
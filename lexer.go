@@ -1,44 +1,376 @@
-package main
+package ged
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"iter"
+	"maps"
+	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
 
-
 var EOF = errors.New("End of input reached")
 var UnknownTokenError = errors.New("Unknown token!")
+var ErrUnterminatedString = errors.New("Unterminated string literal")
+var ErrUnterminatedComment = errors.New("Unterminated block comment")
+var ErrUnknownEscape = errors.New("Unknown escape sequence")
+var ErrMalformedNumber = errors.New("Malformed number")
+var ErrInvalidDigit = errors.New("Invalid digit in number literal")
+var ErrInputTooLarge = errors.New("Input too large")
+var ErrInvalidChar = errors.New("Invalid character literal")
+var ErrNumberRange = errors.New("Number literal out of range")
+var ErrInvalidEscape = errors.New("Invalid escape sequence")
+var ErrInvalidNumberSuffix = errors.New("Invalid number suffix")
+
+// LexError reports a lexing failure at a position in the input. Err holds
+// the sentinel describing the kind of failure.
+type LexError struct {
+	Err     error
+	Lexeme  string
+	Line    int
+	Column  int
+	Message string
+}
 
-type tokenType int
+func (e *LexError) Error() string {
+	return fmt.Sprintf("lex error at line %d, col %d: %s", e.Line, e.Column, e.Message)
+}
+
+func (e *LexError) Unwrap() error {
+	return e.Err
+}
+
+type TokenType int
 
 const (
-	let tokenType = iota
-	identifier
-	number
-	str
-	plus
-	minus
-	eq
-	semicolon
+	Let TokenType = iota
+	If
+	Then
+	Else
+	In
+	Where
+	Identifier
+	// Underscore is a lone _. It may stand in for the name of a let or a
+	// parameter whose value is ignored, as _ can never be read back.
+	Underscore
+	IntNumber
+	FloatNumber
+	Str
+	CharLiteral
+	InterpStart
+	InterpMiddle
+	InterpEnd
+	Boolean
+	Plus
+	Minus
+	Asterisk
+	Power
+	Slash
+	Percent
+	Bang
+	Eq
+	// PlusEq, MinusEq, AsteriskEq and SlashEq are the compound assignments
+	// +=, -=, *= and /=.
+	PlusEq
+	MinusEq
+	AsteriskEq
+	SlashEq
+	Equal
+	NotEqual
+	Less
+	LessEqual
+	Greater
+	GreaterEqual
+	And
+	Or
+	Pipe
+	Semicolon
+	// Newline is a line break that ends a statement, which only a lexer in
+	// ModeNewline produces.
+	Newline
+	LParen
+	RParen
+	Comma
+	Arrow
+	Colon
+	Dot
+	LBracket
+	RBracket
+	LBrace
+	RBrace
+	// Comment is only produced for a lexer with KeepComments set.
+	Comment
+	// Eof ends the slice returned by Tokenize.
+	Eof
 )
 
-type keyword string
+var tokenNames = [...]string{
+	Let:          "let",
+	If:           "if",
+	Then:         "then",
+	Else:         "else",
+	In:           "in",
+	Where:        "where",
+	Identifier:   "identifier",
+	Underscore:   "underscore",
+	IntNumber:    "intNumber",
+	FloatNumber:  "floatNumber",
+	Str:          "str",
+	CharLiteral:  "charLiteral",
+	InterpStart:  "interpStart",
+	InterpMiddle: "interpMiddle",
+	InterpEnd:    "interpEnd",
+	Boolean:      "boolean",
+	Plus:         "plus",
+	Minus:        "minus",
+	Asterisk:     "asterisk",
+	Power:        "power",
+	Slash:        "slash",
+	Percent:      "percent",
+	Bang:         "bang",
+	Eq:           "eq",
+	PlusEq:       "plusEq",
+	MinusEq:      "minusEq",
+	AsteriskEq:   "asteriskEq",
+	SlashEq:      "slashEq",
+	Equal:        "equal",
+	NotEqual:     "notEqual",
+	Less:         "less",
+	LessEqual:    "lessEqual",
+	Greater:      "greater",
+	GreaterEqual: "greaterEqual",
+	And:          "and",
+	Or:           "or",
+	Pipe:         "pipe",
+	Semicolon:    "semicolon",
+	Newline:      "newline",
+	LParen:       "lparen",
+	RParen:       "rparen",
+	Comma:        "comma",
+	Arrow:        "arrow",
+	Colon:        "colon",
+	Dot:          "dot",
+	LBracket:     "lbracket",
+	RBracket:     "rbracket",
+	LBrace:       "lbrace",
+	RBrace:       "rbrace",
+	Comment:      "comment",
+	Eof:          "eof",
+}
+
+func (t TokenType) String() string {
+	if t >= 0 && int(t) < len(tokenNames) && tokenNames[t] != "" {
+		return tokenNames[t]
+	}
+	return fmt.Sprintf("TokenType(%d)", int(t))
+}
+
+// tokenCategory groups token types for tools such as highlighters. Types
+// missing from tokenCategories, like identifiers and punctuation, belong to
+// none of the groups.
+type tokenCategory int
 
 const (
-	letKeyword keyword = "let"
+	keywordCategory tokenCategory = iota + 1
+	literalCategory
+	operatorCategory
 )
 
-type token struct {
-	value     string
-	tokenType tokenType
+var tokenCategories = map[TokenType]tokenCategory{
+	Let:          keywordCategory,
+	If:           keywordCategory,
+	Then:         keywordCategory,
+	Else:         keywordCategory,
+	In:           keywordCategory,
+	Where:        keywordCategory,
+	IntNumber:    literalCategory,
+	FloatNumber:  literalCategory,
+	Str:          literalCategory,
+	CharLiteral:  literalCategory,
+	InterpStart:  literalCategory,
+	InterpMiddle: literalCategory,
+	InterpEnd:    literalCategory,
+	Boolean:      literalCategory,
+	Plus:         operatorCategory,
+	Minus:        operatorCategory,
+	Asterisk:     operatorCategory,
+	Power:        operatorCategory,
+	Slash:        operatorCategory,
+	Percent:      operatorCategory,
+	Bang:         operatorCategory,
+	Eq:           operatorCategory,
+	PlusEq:       operatorCategory,
+	MinusEq:      operatorCategory,
+	AsteriskEq:   operatorCategory,
+	SlashEq:      operatorCategory,
+	Equal:        operatorCategory,
+	NotEqual:     operatorCategory,
+	Less:         operatorCategory,
+	LessEqual:    operatorCategory,
+	Greater:      operatorCategory,
+	GreaterEqual: operatorCategory,
+	And:          operatorCategory,
+	Or:           operatorCategory,
+	Pipe:         operatorCategory,
+	Arrow:        operatorCategory,
+}
+
+func (t TokenType) IsKeyword() bool  { return tokenCategories[t] == keywordCategory }
+func (t TokenType) IsLiteral() bool  { return tokenCategories[t] == literalCategory }
+func (t TokenType) IsOperator() bool { return tokenCategories[t] == operatorCategory }
+
+// MarshalText encodes t as its name, so JSON holds "intNumber" rather
+// than a number.
+func (t TokenType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *TokenType) UnmarshalText(text []byte) error {
+	for i, name := range tokenNames {
+		if name == string(text) {
+			*t = TokenType(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown token type %q", text)
 }
 
+var keywords = map[string]TokenType{
+	"let":   Let,
+	"if":    If,
+	"then":  Then,
+	"else":  Else,
+	"in":    In,
+	"where": Where,
+	"true":  Boolean,
+	"false": Boolean,
+}
+
+// Token is a lexeme of the input. Line and Column are one-based and count
+// every rune before the token, white space included, so a token after a
+// blank first line and three tabs is at line 2, column 4.
+type Token struct {
+	Value  string    `json:"value"`
+	Type   TokenType `json:"type"`
+	Line   int       `json:"line"`
+	Column int       `json:"column"`
+	// Parsed holds the Go value of a lexed number or boolean literal: an
+	// int64, float64 or bool. It is nil for other tokens.
+	Parsed any `json:"-"`
+	// Offset and End are the byte offsets of the token in the input, End
+	// being just past its last byte.
+	Offset int `json:"-"`
+	End    int `json:"-"`
+}
+
+func (t Token) String() string {
+	return fmt.Sprintf("{%s %s}", t.Type, t.Value)
+}
+
+// Mode selects how the lexer treats line breaks.
+type Mode int
+
+// ModeFreeForm, the default, treats line breaks as white space, leaving
+// statements to be ended by semicolons.
+//
+// ModeNewline also lets a line break end a statement, producing a Newline
+// token that the parser takes as a semicolon. Only a break after something
+// that can end an expression counts, such as a name, a literal or a closing
+// bracket, and not one inside parentheses or square brackets, so
+//
+//	let total = price +
+//	    tax
+//	let sum = (a
+//	    + b)
+//
+// are one statement each. A break is also skipped when the next line starts
+// with then, else, in, where, |> or a closing brace, which can only
+// continue what came before.
+//
+// Other values are reserved for an indentation-sensitive mode.
+const (
+	ModeFreeForm Mode = iota
+	ModeNewline
+)
+
 type Lexer struct {
 	Input string
-	pos int
+	Mode  Mode
+	// Strict rejects a number run straight into a name, such as 420foo,
+	// which is usually a typo. Otherwise it lexes as 420 followed by foo.
+	Strict bool
+	// KeepComments makes comments Comment tokens holding their text, from
+	// the // or /* to the end of the line or the */, instead of white space.
+	KeepComments bool
+	// TargetIntBits is the width of the integers the program runs with.
+	// An integer literal too large for a signed integer of that width is
+	// an ErrNumberRange error, so with 32 the largest is 2147483647. Zero
+	// means 64, the width the evaluator uses.
+	TargetIntBits int
+	// Keywords, when non-nil, replaces the table of reserved words, so a
+	// variant of the language can rename them or add synonyms such as fn
+	// for let. Words missing from it lex as names. Only true and false may
+	// map to Boolean.
+	Keywords map[string]TokenType
+	// MaxInputBytes and MaxTokens bound the work done on untrusted input.
+	// Zero means unlimited.
+	MaxInputBytes int
+	MaxTokens     int
+
+	pos   int
 	width int
+	// line and column are zero-based; tokens report them one-based
+	line       int
+	column     int
+	prevLine   int
+	prevColumn int
+	// interps holds the ${ whose expressions are being lexed, innermost last
+	interps []interpolation
+	// count is the number of tokens returned so far
+	count int
+	// buf is scratch space for decoding string contents, reused between
+	// strings
+	buf []byte
+	// last is the type of the last token returned other than a comment, and
+	// brackets holds the (, [ and { not yet closed, innermost last. They
+	// decide which line breaks end a statement in ModeNewline.
+	last     TokenType
+	brackets []rune
+}
+
+// stringStart records where a string literal began, for errors.
+type stringStart struct {
+	start, line, column int
+}
+
+// interpolation is a ${ whose closing brace has not been reached yet.
+type interpolation struct {
+	// str is the string the ${ appears in
+	str                 stringStart
+	start, line, column int
+	// depth counts the braces opened inside the expression and not yet
+	// closed, so the } that ends the interpolation can be told apart
+	depth int
+}
+
+// NewLexer returns a lexer positioned at the start of input.
+func NewLexer(input string) *Lexer {
+	return &Lexer{Input: input}
+}
+
+// Reset prepares the lexer to tokenize input from the beginning.
+func (l *Lexer) Reset(input string) {
+	l.Input = input
+	l.pos, l.width = 0, 0
+	l.line, l.column = 0, 0
+	l.prevLine, l.prevColumn = 0, 0
+	l.interps = nil
+	l.count = 0
+	l.last = 0
+	l.brackets = nil
 }
 
 func (l *Lexer) next() (rune, error) {
@@ -48,146 +380,1050 @@ func (l *Lexer) next() (rune, error) {
 	r, width := utf8.DecodeRuneInString(l.Input[l.pos:])
 	l.width = width
 	l.pos += width
+	l.prevLine, l.prevColumn = l.line, l.column
+	// \r\n is one break, counted at the \n; a lone \r is a break by itself
+	if r == '\n' || r == '\r' && !strings.HasPrefix(l.Input[l.pos:], "\n") {
+		l.line++
+		l.column = 0
+	} else {
+		l.column++
+	}
 	return r, nil
 }
 
 func (l *Lexer) peek() (rune, error) {
-	r, err := l.next()
-	if err != nil {
-		return r, err
+	return l.peekAt(0)
+}
+
+// peekAt returns the rune n runes past the current one without consuming
+// anything, or EOF if the input ends first.
+func (l *Lexer) peekAt(n int) (rune, error) {
+	for pos := l.pos; pos < len(l.Input); n-- {
+		r, width := utf8.DecodeRuneInString(l.Input[pos:])
+		if n == 0 {
+			return r, nil
+		}
+		pos += width
 	}
-	l.backup()
-	return r, nil
+	return -1, EOF
 }
 
 func (l *Lexer) backup() {
 	l.pos -= l.width
+	l.line, l.column = l.prevLine, l.prevColumn
 }
 
-func (l *Lexer) tokenize() ([]token, error) {
-	tokens := make([]token, 0)
+// accept consumes the next rune if it is one of valid.
+func (l *Lexer) accept(valid string) bool {
+	r, err := l.peek()
+	if err != nil || !strings.ContainsRune(valid, r) {
+		return false
+	}
+	l.next()
+	return true
+}
+
+// acceptRun consumes runes while valid reports true and returns how many
+// were consumed.
+func (l *Lexer) acceptRun(valid func(rune) bool) int {
+	n := 0
 	for {
-		if err := l.skipWhiteSpace(); err != nil {
-			return tokens, err
-		}
 		r, err := l.peek()
+		if err != nil || !valid(r) {
+			return n
+		}
+		l.next()
+		n++
+	}
+}
+
+func (l *Lexer) position() (int, int) {
+	return l.line + 1, l.column + 1
+}
+
+// eof returns the token that marks the end of input, positioned just past
+// the last rune.
+func (l *Lexer) eof() Token {
+	line, column := l.position()
+	return Token{Type: Eof, Line: line, Column: column, Offset: l.pos, End: l.pos}
+}
+
+// Tokenize lexes the whole input. The returned tokens always end with an
+// Eof token unless lexing fails, which is always reported as a *LexError,
+// whatever the input.
+func (l *Lexer) Tokenize() ([]Token, error) {
+	tokens := make([]Token, 0, l.estimateTokens())
+	for tok, err := range l.Tokens() {
 		if err != nil {
 			return tokens, err
 		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
 
-		switch {
-		case r == '=':
-			tokens = append(tokens, token{value: string(r), tokenType: eq})
-			l.next()
-		case r == '+':
-			tokens = append(tokens, token{value: string(r), tokenType: plus})
-			l.next()
-		case r == '-':
-			tokens = append(tokens, token{value: string(r), tokenType: minus})
-			l.next()
-		case r == ';':
-			tokens = append(tokens, token{value: string(r), tokenType: semicolon})
-			l.next()
-		case r == '"':
-			str, err := l.readString()
-			if err != nil {
-				return tokens, err
+// Tokens lexes the input lazily, yielding each token as it is read up to
+// and including the final Eof token. A lexing error is yielded on its own
+// and ends the sequence.
+func (l *Lexer) Tokens() iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		for {
+			tok, err := l.Next()
+			if errors.Is(err, EOF) {
+				yield(l.eof(), nil)
+				return
 			}
-			tokens = append(tokens, str)
-		case unicode.IsDigit(r) || r == '.':
-			num, err := l.readNum()
 			if err != nil {
-				return tokens, err
+				yield(Token{}, err)
+				return
 			}
-			tokens = append(tokens, num)
-		case unicode.IsLetter(r):
-			ident, err := l.readIdentOrKeyword()
-			if err != nil {
-				return tokens, err
+			if !yield(tok, nil) {
+				return
 			}
-			tokens = append(tokens, ident)
-		default:
-			return tokens, UnknownTokenError
 		}
 	}
 }
 
+// estimateTokens guesses how many tokens the input holds, so Tokenize can
+// size its slice up front. Typical source averages a little under four
+// bytes per token; erring high avoids regrowing the slice.
+func (l *Lexer) estimateTokens() int {
+	n := len(l.Input)/3 + 1
+	if l.MaxTokens > 0 && n > l.MaxTokens+1 {
+		n = l.MaxTokens + 1
+	}
+	if l.MaxInputBytes > 0 && len(l.Input) > l.MaxInputBytes {
+		n = 0
+	}
+	return n
+}
+
+// TokensJSON lexes input and encodes the tokens as a JSON array. A lexing
+// error is returned without any output.
+func TokensJSON(input string) ([]byte, error) {
+	tokens, err := NewLexer(input).Tokenize()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tokens)
+}
+
+// TokenStats lexes input and counts the tokens of each type. The Eof token
+// that ends every token stream is left out, so counts always add up to the
+// number of tokens actually in the source.
+func TokenStats(input string) (map[TokenType]int, error) {
+	tokens, err := NewLexer(input).Tokenize()
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[TokenType]int)
+	for _, tok := range tokens {
+		if tok.Type != Eof {
+			stats[tok.Type]++
+		}
+	}
+	return stats, nil
+}
+
+// Next returns the next token of the input, or EOF once it is exhausted.
+// Input longer than MaxInputBytes, or a token beyond the first MaxTokens,
+// fails with ErrInputTooLarge.
+func (l *Lexer) Next() (Token, error) {
+	if l.MaxInputBytes > 0 && len(l.Input) > l.MaxInputBytes {
+		return Token{}, &LexError{
+			Err:     ErrInputTooLarge,
+			Line:    1,
+			Column:  1,
+			Message: fmt.Sprintf("input is %d bytes, limit is %d", len(l.Input), l.MaxInputBytes),
+		}
+	}
+	tok, err := l.scan()
+	if err != nil || l.MaxTokens == 0 {
+		return tok, err
+	}
+	if l.count == l.MaxTokens {
+		return Token{}, &LexError{
+			Err:     ErrInputTooLarge,
+			Lexeme:  tok.Value,
+			Line:    tok.Line,
+			Column:  tok.Column,
+			Message: fmt.Sprintf("more than %d tokens", l.MaxTokens),
+		}
+	}
+	l.count++
+	return tok, nil
+}
+
+func (l *Lexer) scan() (Token, error) {
+	if err := l.skipWhiteSpace(); err != nil {
+		if errors.Is(err, EOF) && l.endsStatement() {
+			// the last line need not end in a break
+			l.last = Newline
+			line, column := l.position()
+			return Token{Type: Newline, Line: line, Column: column, Offset: l.pos, End: l.pos}, nil
+		}
+		if errors.Is(err, EOF) && len(l.interps) > 0 {
+			open := l.interps[len(l.interps)-1]
+			return Token{}, &LexError{
+				Err:     ErrUnterminatedString,
+				Lexeme:  l.Input[open.start:],
+				Line:    open.line,
+				Column:  open.column,
+				Message: "unterminated interpolation",
+			}
+		}
+		return Token{}, err
+	}
+	r, err := l.peek()
+	if err != nil {
+		return Token{}, err
+	}
+	start := l.pos
+	tok, err := l.scanToken(r)
+	if err != nil {
+		return Token{}, err
+	}
+	tok.Offset, tok.End = start, l.pos
+	if tok.Type != Comment {
+		l.last = tok.Type
+	}
+	return tok, nil
+}
+
+// scanToken reads the token that starts with r.
+func (l *Lexer) scanToken(r rune) (Token, error) {
+	switch {
+	case r == '}' && len(l.interps) > 0 && l.interps[len(l.interps)-1].depth == 0:
+		return l.resumeString()
+	case r == '=':
+		return l.readOperator(Eq, "=", Equal), nil
+	case r == '<':
+		return l.readOperator(Less, "=", LessEqual), nil
+	case r == '>':
+		return l.readOperator(Greater, "=", GreaterEqual), nil
+	case r == '!':
+		return l.readOperator(Bang, "=", NotEqual), nil
+	case r == '&':
+		return l.readDouble(r, And)
+	case r == '|' && l.startsPipe():
+		return l.readPair(Pipe), nil
+	case r == '|':
+		return l.readDouble(r, Or)
+	case r == '+':
+		return l.readOperator(Plus, "=", PlusEq), nil
+	case r == '-':
+		return l.readOperator(Minus, ">=", Arrow, MinusEq), nil
+	case r == '*':
+		return l.readOperator(Asterisk, "*=", Power, AsteriskEq), nil
+	case r == '/' && l.startsComment():
+		return l.readComment()
+	case r == '/':
+		return l.readOperator(Slash, "=", SlashEq), nil
+	case r == '%':
+		return l.readSingle(r, Percent), nil
+	case r == ';':
+		return l.readSingle(r, Semicolon), nil
+	case r == '\n' || r == '\r':
+		return l.readNewline(), nil
+	case r == '(':
+		l.open(r)
+		return l.readSingle(r, LParen), nil
+	case r == ')':
+		l.close()
+		return l.readSingle(r, RParen), nil
+	case r == ',':
+		return l.readSingle(r, Comma), nil
+	case r == ':':
+		return l.readSingle(r, Colon), nil
+	case r == '[':
+		l.open(r)
+		return l.readSingle(r, LBracket), nil
+	case r == ']':
+		l.close()
+		return l.readSingle(r, RBracket), nil
+	case r == '{':
+		l.open(r)
+		l.nestBraces(1)
+		return l.readSingle(r, LBrace), nil
+	case r == '}':
+		l.close()
+		l.nestBraces(-1)
+		return l.readSingle(r, RBrace), nil
+	case r == '"':
+		return l.readString()
+	case r == '\'':
+		return l.readChar()
+	case r == '.' && !l.startsFraction():
+		return l.readSingle(r, Dot), nil
+	case unicode.IsDigit(r) || r == '.':
+		return l.readNum()
+	case isIdentStart(r):
+		return l.readIdentOrKeyword()
+	default:
+		line, column := l.position()
+		return Token{}, unknownToken(r, line, column)
+	}
+}
+
+func unknownToken(r rune, line, column int) error {
+	return &LexError{
+		Err:     UnknownTokenError,
+		Lexeme:  string(r),
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf("unknown token '%c'", r),
+	}
+}
+
+func (l *Lexer) readSingle(r rune, t TokenType) Token {
+	line, column := l.position()
+	start := l.pos
+	l.next()
+	return Token{Value: l.Input[start:l.pos], Type: t, Line: line, Column: column}
+}
+
+// readOperator consumes a one-rune operator, or a two-rune one when the
+// following rune is in seconds; the two-rune operator ending in seconds[i]
+// has type doubles[i].
+func (l *Lexer) readOperator(single TokenType, seconds string, doubles ...TokenType) Token {
+	line, column := l.position()
+	start := l.pos
+	t := single
+	if r, err := l.peekAt(1); err == nil {
+		if i := strings.IndexRune(seconds, r); i >= 0 {
+			t = doubles[i]
+			l.next()
+		}
+	}
+	l.next()
+	return Token{Value: l.Input[start:l.pos], Type: t, Line: line, Column: column}
+}
+
+// readPair consumes an operator of two runes, which the caller has already
+// looked at.
+func (l *Lexer) readPair(t TokenType) Token {
+	line, column := l.position()
+	start := l.pos
+	l.next()
+	l.next()
+	return Token{Value: l.Input[start:l.pos], Type: t, Line: line, Column: column}
+}
+
+// readDouble consumes an operator written as r twice, such as &&. A lone r
+// is an unknown token.
+func (l *Lexer) readDouble(r rune, t TokenType) (Token, error) {
+	line, column := l.position()
+	start := l.pos
+	l.next()
+	if !l.accept(string(r)) {
+		return Token{}, unknownToken(r, line, column)
+	}
+	return Token{Value: l.Input[start:l.pos], Type: t, Line: line, Column: column}, nil
+}
+
+// isStatementBreak reports whether the white space r is significant, ending
+// a statement rather than being skipped. In free-form mode none is.
+func (l *Lexer) isStatementBreak(r rune) bool {
+	switch l.Mode {
+	case ModeFreeForm:
+		return false
+	case ModeNewline:
+		return (r == '\n' || r == '\r') && l.endsStatement() && !l.continuesLine()
+	}
+	// the reserved modes lex as free-form until they are implemented
+	return false
+}
+
+// endsStatement reports whether the statement being lexed may end here in
+// ModeNewline: after an operand, and outside parentheses, square brackets
+// and interpolations.
+func (l *Lexer) endsStatement() bool {
+	if l.Mode != ModeNewline || len(l.interps) > 0 {
+		return false
+	}
+	if n := len(l.brackets); n > 0 && l.brackets[n-1] != '{' {
+		return false
+	}
+	switch l.last {
+	case Identifier, Underscore, IntNumber, FloatNumber, Str, CharLiteral, InterpEnd, Boolean, RParen, RBracket, RBrace:
+		return true
+	}
+	return false
+}
+
+// continuesLine reports whether the next line starts with a token that
+// carries on the statement before the break.
+func (l *Lexer) continuesLine() bool {
+	rest := strings.TrimLeftFunc(l.Input[l.pos:], unicode.IsSpace)
+	if strings.HasPrefix(rest, "}") || strings.HasPrefix(rest, "|>") {
+		return true
+	}
+	word := rest[:len(rest)-len(strings.TrimLeftFunc(rest, isIdentRune))]
+	switch l.keywordTable()[word] {
+	case Then, Else, In, Where:
+		return true
+	}
+	return false
+}
+
+// readNewline reads a line break that ends a statement, taking \r\n as one.
+func (l *Lexer) readNewline() Token {
+	line, column := l.position()
+	start := l.pos
+	if r, _ := l.next(); r == '\r' {
+		l.accept("\n")
+	}
+	return Token{Value: l.Input[start:l.pos], Type: Newline, Line: line, Column: column}
+}
+
+func (l *Lexer) open(r rune) {
+	l.brackets = append(l.brackets, r)
+}
+
+func (l *Lexer) close() {
+	if n := len(l.brackets); n > 0 {
+		l.brackets = l.brackets[:n-1]
+	}
+}
+
 func (l *Lexer) skipWhiteSpace() error {
 	for {
 		r, err := l.peek()
 		if err != nil {
 			return err
 		}
-		if !unicode.IsSpace(r) {
+		switch {
+		case unicode.IsSpace(r) && !l.isStatementBreak(r):
+			l.next()
+		case r == '/' && l.startsComment():
+			if l.KeepComments {
+				return nil
+			}
+			if _, err := l.readComment(); err != nil {
+				return err
+			}
+		default:
 			return nil
 		}
-		l.next()
 	}
 }
 
-func (l *Lexer) readString() (token, error) {
+// startsPipe reports whether the | being read begins |>. A lone | is
+// reserved for a bitwise or.
+func (l *Lexer) startsPipe() bool {
+	r, err := l.peekAt(1)
+	return err == nil && r == '>'
+}
+
+func (l *Lexer) startsComment() bool {
+	r, err := l.peekAt(1)
+	return err == nil && (r == '/' || r == '*')
+}
+
+// readComment reads a line or block comment. A line comment stops before
+// the line break, which is left to end the statement in ModeNewline.
+func (l *Lexer) readComment() (Token, error) {
+	line, column := l.position()
+	start := l.pos
+	// consume the opening slash
+	l.next()
+	if r, _ := l.next(); r == '/' {
+		for {
+			r, err := l.next()
+			if err == nil && (r == '\n' || r == '\r') {
+				l.backup()
+			}
+			if err != nil || r == '\n' || r == '\r' {
+				return Token{Value: l.Input[start:l.pos], Type: Comment, Line: line, Column: column}, nil
+			}
+		}
+	}
+	for {
+		r, err := l.next()
+		if err != nil {
+			return Token{}, &LexError{
+				Err:     ErrUnterminatedComment,
+				Lexeme:  l.Input[start:],
+				Line:    line,
+				Column:  column,
+				Message: "unterminated block comment",
+			}
+		}
+		if r == '*' && strings.HasPrefix(l.Input[l.pos:], "/") {
+			l.next()
+			return Token{Value: l.Input[start:l.pos], Type: Comment, Line: line, Column: column}, nil
+		}
+	}
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+	'$':  '$',
+}
+
+func (l *Lexer) readString() (Token, error) {
+	if strings.HasPrefix(l.Input[l.pos:], `"""`) {
+		return l.readRawString()
+	}
+	line, column := l.position()
+	start := l.pos
 	// consume open quote
 	if _, err := l.next(); err != nil {
-		return token{}, err
+		return Token{}, err
+	}
+	return l.readStringPart(stringStart{start, line, column}, line, column, Str, InterpStart)
+}
+
+// resumeString continues an interpolated string after the } that closes
+// one of its ${...} expressions.
+func (l *Lexer) resumeString() (Token, error) {
+	line, column := l.position()
+	l.next()
+	open := l.interps[len(l.interps)-1]
+	l.interps = l.interps[:len(l.interps)-1]
+	return l.readStringPart(open.str, line, column, InterpEnd, InterpMiddle)
+}
+
+// nestBraces tracks a { or } inside the innermost interpolation.
+func (l *Lexer) nestBraces(delta int) {
+	if n := len(l.interps); n > 0 {
+		l.interps[n-1].depth += delta
 	}
+}
+
+// readStringPart reads string contents up to the closing quote, producing
+// a token of type end, or up to a ${, producing one of type interp. The
+// token is stamped with line and column; open is where the string began.
+// A backslash at the end of a line continues the string on the next one,
+// leaving out the line break.
+//
+// Contents are decoded into the lexer's scratch buffer, but while they hold
+// no escapes the value is taken straight from the input without copying.
+func (l *Lexer) readStringPart(open stringStart, line, column int, end, interp TokenType) (Token, error) {
 	start := l.pos
+	verbatim := true
+	value := l.buf[:0]
+	defer func() { l.buf = value[:0] }()
+	text := func(stop int) string {
+		if verbatim {
+			return l.Input[start:stop]
+		}
+		return string(value)
+	}
 	for {
+		runeLine, runeColumn := l.position()
 		r, err := l.next()
+		if errors.Is(err, EOF) {
+			return Token{}, l.unterminatedString(open.start, open.line, open.column)
+		}
+		if err != nil {
+			return Token{}, err
+		}
+		switch r {
+		case '"':
+			return Token{Value: text(l.pos - len(`"`)), Type: end, Line: line, Column: column}, nil
+		case '$':
+			if !l.accept("{") {
+				value = utf8.AppendRune(value, r)
+				continue
+			}
+			l.interps = append(l.interps, interpolation{
+				str:    open,
+				start:  l.pos - len("${"),
+				line:   runeLine,
+				column: runeColumn,
+			})
+			return Token{Value: text(l.pos - len("${")), Type: interp, Line: line, Column: column}, nil
+		case '\\':
+			if l.accept("\r\n") {
+				// a backslash before a line break joins the lines
+				if l.Input[l.pos-1] == '\r' {
+					l.accept("\n")
+				}
+				verbatim = false
+				continue
+			}
+			decoded, err := l.readEscape(runeLine, runeColumn)
+			if errors.Is(err, EOF) {
+				return Token{}, l.unterminatedString(open.start, open.line, open.column)
+			}
+			if err != nil {
+				return Token{}, err
+			}
+			verbatim = false
+			value = utf8.AppendRune(value, decoded)
+		default:
+			// invalid UTF-8 decodes to U+FFFD, which the input doesn't hold
+			if r == utf8.RuneError {
+				verbatim = false
+			}
+			value = utf8.AppendRune(value, r)
+		}
+	}
+}
+
+// readEscape decodes the escape sequence following a backslash at line and
+// column.
+func (l *Lexer) readEscape(line, column int) (rune, error) {
+	start := l.pos - len(`\`)
+	r, err := l.next()
+	if err != nil {
+		return 0, err
+	}
+	switch r {
+	case 'x':
+		return l.readHexEscape(start, line, column)
+	case 'u':
+		return l.readUnicodeEscape(start, line, column)
+	}
+	decoded, ok := escapes[r]
+	if !ok {
+		return 0, &LexError{
+			Err:     ErrUnknownEscape,
+			Lexeme:  "\\" + string(r),
+			Line:    line,
+			Column:  column,
+			Message: fmt.Sprintf("unknown escape sequence '\\%c'", r),
+		}
+	}
+	return decoded, nil
+}
+
+// readHexEscape reads the two hex digits of a \xHH escape. Only ASCII may
+// be written this way, which keeps strings valid UTF-8; other characters
+// take a \u{...} escape.
+func (l *Lexer) readHexEscape(start, line, column int) (rune, error) {
+	var value rune
+	for range 2 {
+		r, err := l.peek()
 		if err != nil {
-			return token{}, err
+			return 0, err
+		}
+		digit, ok := hexDigit(r)
+		if !ok {
+			return 0, l.invalidEscape(start, line, column, `\x must be followed by two hex digits`)
 		}
-		if r == '"' {
+		l.next()
+		value = value<<4 | digit
+	}
+	if value > unicode.MaxASCII {
+		return 0, l.invalidEscape(start, line, column, fmt.Sprintf(`\x escapes stop at \x7f, use \u{%x}`, value))
+	}
+	return value, nil
+}
+
+// readUnicodeEscape reads the code point of a \u{...} escape, written with
+// one to six hex digits.
+func (l *Lexer) readUnicodeEscape(start, line, column int) (rune, error) {
+	r, err := l.peek()
+	if err != nil {
+		return 0, err
+	}
+	if r != '{' {
+		return 0, l.invalidEscape(start, line, column, `\u must be followed by {hex digits}`)
+	}
+	l.next()
+	var value rune
+	digits := 0
+	for {
+		r, err := l.peek()
+		if err != nil {
+			return 0, err
+		}
+		if r == '}' {
 			break
 		}
+		digit, ok := hexDigit(r)
+		if !ok || digits == 6 {
+			return 0, l.invalidEscape(start, line, column, "expected one to six hex digits and a closing }")
+		}
+		l.next()
+		value = value<<4 | digit
+		digits++
+	}
+	l.next()
+	if digits == 0 {
+		return 0, l.invalidEscape(start, line, column, "missing hex digits")
+	}
+	if !utf8.ValidRune(value) {
+		return 0, l.invalidEscape(start, line, column, fmt.Sprintf("U+%04X is not a valid code point", value))
 	}
-	value := l.Input[start:l.pos-1]
-	return token{value: value, tokenType: str}, nil
+	return value, nil
 }
 
-func (l *Lexer) readNum() (token, error) {
+func (l *Lexer) invalidEscape(start, line, column int, reason string) error {
+	lexeme := l.Input[start:l.pos]
+	return &LexError{
+		Err:     ErrInvalidEscape,
+		Lexeme:  lexeme,
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf("invalid escape sequence '%s': %s", lexeme, reason),
+	}
+}
+
+func hexDigit(r rune) (rune, bool) {
+	switch {
+	case '0' <= r && r <= '9':
+		return r - '0', true
+	case 'a' <= r && r <= 'f':
+		return r - 'a' + 10, true
+	case 'A' <= r && r <= 'F':
+		return r - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// readChar reads a single-quoted character literal, which must hold exactly
+// one rune once escapes are decoded.
+func (l *Lexer) readChar() (Token, error) {
+	line, column := l.position()
 	start := l.pos
+	// consume the opening quote
+	l.next()
+	var runes []rune
 	for {
+		runeLine, runeColumn := l.position()
 		r, err := l.next()
+		if errors.Is(err, EOF) || r == '\n' || r == '\r' {
+			return Token{}, l.invalidChar(start, line, column, "unterminated character literal")
+		}
 		if err != nil {
-			return token{}, err
+			return Token{}, err
 		}
-		if !unicode.IsDigit(r) && r != '.' {
+		if r == '\'' {
 			break
 		}
+		if r == '\\' {
+			if r, err = l.readEscape(runeLine, runeColumn); errors.Is(err, EOF) {
+				return Token{}, l.invalidChar(start, line, column, "unterminated character literal")
+			}
+			if err != nil {
+				return Token{}, err
+			}
+		}
+		runes = append(runes, r)
 	}
-	value := l.Input[start:l.pos]
-	return token{value: value, tokenType: number}, nil
+	switch {
+	case len(runes) == 0:
+		return Token{}, l.invalidChar(start, line, column, "empty character literal")
+	case len(runes) > 1:
+		return Token{}, l.invalidChar(start, line, column, "character literal holds more than one character")
+	}
+	return Token{Value: string(runes[0]), Type: CharLiteral, Line: line, Column: column}, nil
 }
 
-func (l *Lexer) readIdentOrKeyword() (token, error) {
+func (l *Lexer) invalidChar(start, line, column int, message string) error {
+	return &LexError{
+		Err:     ErrInvalidChar,
+		Lexeme:  l.Input[start:l.pos],
+		Line:    line,
+		Column:  column,
+		Message: message,
+	}
+}
+
+// readRawString reads a """-delimited string whose contents, newlines
+// included, are taken verbatim.
+func (l *Lexer) readRawString() (Token, error) {
+	line, column := l.position()
 	start := l.pos
+	// consume the opening quotes
+	for range 3 {
+		l.next()
+	}
+	end := strings.Index(l.Input[l.pos:], `"""`)
+	if end < 0 {
+		return Token{}, l.unterminatedString(start, line, column)
+	}
+	value := l.Input[l.pos : l.pos+end]
+	// step through the contents so line and column stay in sync
+	for range utf8.RuneCountInString(value) + 3 {
+		l.next()
+	}
+	return Token{Value: value, Type: Str, Line: line, Column: column}, nil
+}
+
+// unterminatedString reports an unclosed string literal at its opening quote.
+func (l *Lexer) unterminatedString(start, line, column int) error {
+	return &LexError{
+		Err:     ErrUnterminatedString,
+		Lexeme:  l.Input[start:],
+		Line:    line,
+		Column:  column,
+		Message: "unterminated string literal",
+	}
+}
+
+var radixDigits = map[byte]string{
+	'x': "0123456789abcdefABCDEF",
+	'o': "01234567",
+	'b': "01",
+}
+
+// startsFraction reports whether the . at the current position begins a
+// number like .5. A . straight after an operand is always member access, so
+// a.5 is a, dot and 5.
+func (l *Lexer) startsFraction() bool {
+	if r, err := l.peekAt(1); err != nil || !unicode.IsDigit(r) {
+		return false
+	}
+	prev, _ := utf8.DecodeLastRuneInString(l.Input[:l.pos])
+	return !isIdentRune(prev) && !strings.ContainsRune("')]}", prev)
+}
+
+// readNum reads a number, or an integer in another base after 0x, 0o or 0b.
+// The digits on one side of a decimal point may be left out, so .5 and 5.
+// are both floats, but a . with no digit on either side is a Dot.
+func (l *Lexer) readNum() (Token, error) {
+	if rest := l.Input[l.pos:]; len(rest) > 1 && rest[0] == '0' {
+		if digits, ok := radixDigits[rest[1]]; ok {
+			return l.readRadixInt(digits)
+		}
+	}
+	line, column := l.position()
+	start := l.pos
+	dots := 0
 	for {
-		r, err := l.next()
+		if _, reason := l.acceptDigits(unicode.IsDigit); reason != "" {
+			return Token{}, l.malformedNumber(start, line, column, reason)
+		}
+		if !l.accept(".") {
+			break
+		}
+		dots++
+	}
+	if dots > 1 {
+		return Token{}, l.malformedNumber(start, line, column, "too many decimal points")
+	}
+	if l.accept("eE") {
+		l.accept("+-")
+		n, reason := l.acceptDigits(unicode.IsDigit)
+		if reason != "" {
+			return Token{}, l.malformedNumber(start, line, column, reason)
+		}
+		if n == 0 {
+			return Token{}, l.malformedNumber(start, line, column, "missing exponent digits")
+		}
+		dots = 1
+	}
+	if err := l.checkNumberSuffix(start); err != nil {
+		return Token{}, err
+	}
+	value := strings.ReplaceAll(l.Input[start:l.pos], "_", "")
+	if dots == 0 {
+		return l.number(Token{Value: value, Type: IntNumber, Line: line, Column: column}, start)
+	}
+	return l.number(Token{Value: value, Type: FloatNumber, Line: line, Column: column}, start)
+}
+
+// checkNumberSuffix rejects a name directly after the number that began at
+// start, in strict mode.
+func (l *Lexer) checkNumberSuffix(start int) error {
+	r, err := l.peek()
+	if !l.Strict || err != nil || !isIdentStart(r) {
+		return nil
+	}
+	line, column := l.position()
+	number := l.Input[start:l.pos]
+	suffix := l.pos
+	l.acceptRun(isIdentRune)
+	return &LexError{
+		Err:     ErrInvalidNumberSuffix,
+		Lexeme:  l.Input[start:l.pos],
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf("invalid suffix %q after number %s", l.Input[suffix:l.pos], number),
+	}
+}
+
+func (l *Lexer) readRadixInt(digits string) (Token, error) {
+	line, column := l.position()
+	start := l.pos
+	// consume the 0x/0o/0b prefix
+	l.next()
+	l.next()
+	n, reason := l.acceptDigits(func(r rune) bool { return strings.ContainsRune(digits, r) })
+	if reason != "" {
+		return Token{}, l.malformedNumber(start, line, column, reason)
+	}
+	runeLine, runeColumn := l.position()
+	if r, err := l.peek(); err == nil && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+		return Token{}, &LexError{
+			Err:     ErrInvalidDigit,
+			Lexeme:  string(r),
+			Line:    runeLine,
+			Column:  runeColumn,
+			Message: fmt.Sprintf("invalid digit '%c' in number literal", r),
+		}
+	}
+	if n == 0 {
+		return Token{}, l.malformedNumber(start, line, column, "missing digits")
+	}
+	value := strings.ReplaceAll(l.Input[start:l.pos], "_", "")
+	return l.number(Token{Value: value, Type: IntNumber, Line: line, Column: column}, start)
+}
+
+// number fills in the parsed value of tok, a number literal that began at
+// start, or reports that it is too large to represent.
+func (l *Lexer) number(tok Token, start int) (Token, error) {
+	parsed, err := parseNumber(tok.Type, tok.Value)
+	bits := l.intBits()
+	if i, ok := parsed.(int64); ok && bits < 64 && i > 1<<(bits-1)-1 {
+		err = strconv.ErrRange
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		kind, limit := "integer", fmt.Sprintf("int%d", bits)
+		if tok.Type == FloatNumber {
+			kind, limit = "float", "float64"
+		}
+		return Token{}, &LexError{
+			Err:     ErrNumberRange,
+			Lexeme:  l.Input[start:l.pos],
+			Line:    tok.Line,
+			Column:  tok.Column,
+			Message: fmt.Sprintf("%s literal %s overflows %s", kind, l.Input[start:l.pos], limit),
+		}
+	}
+	if err != nil {
+		return Token{}, l.malformedNumber(start, tok.Line, tok.Column, err.Error())
+	}
+	tok.Parsed = parsed
+	return tok, nil
+}
+
+func (l *Lexer) intBits() int {
+	if l.TargetIntBits <= 0 || l.TargetIntBits > 64 {
+		return 64
+	}
+	return l.TargetIntBits
+}
+
+// parseNumber returns the value of a number literal of type t: an int64
+// for IntNumber and a float64 for FloatNumber.
+func parseNumber(t TokenType, value string) (any, error) {
+	if t == FloatNumber {
+		f, err := strconv.ParseFloat(value, 64)
 		if err != nil {
-			return token{}, err
+			return nil, err
 		}
-		if !unicode.IsLetter(r) && r != '_' {
+		return f, nil
+	}
+	base := 10
+	// base 0 lets ParseInt read the 0x/0o/0b prefixes; plain decimals stay
+	// base 10 so 010 is ten rather than octal
+	if len(value) > 1 && strings.ContainsRune("xob", rune(value[1])) {
+		base = 0
+	}
+	i, err := strconv.ParseInt(value, base, 64)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// acceptDigits consumes a run of digits in which single underscores may
+// separate digits. It returns the number of digits consumed, or the reason
+// the run is malformed.
+func (l *Lexer) acceptDigits(isDigit func(rune) bool) (int, string) {
+	n := 0
+	underscore := false
+	for {
+		r, err := l.peek()
+		if err != nil || (!isDigit(r) && r != '_') {
 			break
 		}
+		if r == '_' {
+			switch {
+			case underscore:
+				return n, "repeated underscore"
+			case n == 0:
+				return n, "underscore must follow a digit"
+			}
+			underscore = true
+		} else {
+			underscore = false
+			n++
+		}
+		l.next()
 	}
-	value := l.Input[start:l.pos]
-	switch {
-	case value == string(letKeyword):
-		return token{value: value, tokenType: let}, nil
-	default:
-		return token{value: value, tokenType: identifier}, nil
+	if underscore {
+		return n, "trailing underscore"
+	}
+	return n, ""
+}
+
+// malformedNumber consumes the rest of a bad literal so the error can quote
+// all of it.
+func (l *Lexer) malformedNumber(start, line, column int, reason string) error {
+	l.acceptRun(func(r rune) bool { return isIdentRune(r) || r == '.' })
+	return &LexError{
+		Err:     ErrMalformedNumber,
+		Lexeme:  l.Input[start:l.pos],
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf("malformed number %q: %s", l.Input[start:l.pos], reason),
 	}
 }
 
+// DefaultKeywords returns a copy of the built-in keyword table, to start a
+// Lexer.Keywords from.
+func DefaultKeywords() map[string]TokenType {
+	return maps.Clone(keywords)
+}
 
-func main() {
-	lexer := Lexer{
-		Input: `
-			println + 420 69;
-			let sayHello a b = printf "Hi, %s!" a;
-			sayHello "world";
-		`,
+func (l *Lexer) keywordTable() map[string]TokenType {
+	if l.Keywords != nil {
+		return l.Keywords
 	}
+	return keywords
+}
+
+// isIdentStart and isIdentRune follow the identifier syntax of Unicode
+// Standard Annex #31, with _ allowed at the start as well: a letter, which
+// includes letter numbers like Ⅻ, then any run of letters, decimal digits,
+// combining marks and connector punctuation. Composed and decomposed forms
+// of an accented letter are both accepted, but are different names.
+// Emoji and other symbols are never part of a name.
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Nl, r) || r == '_'
+}
+
+func isIdentRune(r rune) bool {
+	return isIdentStart(r) || unicode.In(r, unicode.Nd, unicode.Mn, unicode.Mc, unicode.Pc)
+}
 
-	fmt.Println(lexer.tokenize())
+func (l *Lexer) readIdentOrKeyword() (Token, error) {
+	line, column := l.position()
+	start := l.pos
+	l.acceptRun(isIdentRune)
+	l.acceptRun(func(r rune) bool { return r == '\'' })
+	value := l.Input[start:l.pos]
+	// _1 reads as a number with a leading separator, not an identifier
+	if body := strings.TrimLeft(value, "_"); value[0] == '_' && body != "" && strings.Trim(body, "0123456789_") == "" {
+		return Token{}, &LexError{
+			Err:     ErrMalformedNumber,
+			Lexeme:  value,
+			Line:    line,
+			Column:  column,
+			Message: fmt.Sprintf("malformed number %q: leading underscore", value),
+		}
+	}
+	if t, ok := l.keywordTable()[value]; ok {
+		tok := Token{Value: value, Type: t, Line: line, Column: column}
+		if t == Boolean {
+			tok.Parsed = value == "true"
+		}
+		return tok, nil
+	}
+	// only a lone _ is the wildcard; _x and x_ are names
+	if value == "_" {
+		return Token{Value: value, Type: Underscore, Line: line, Column: column}, nil
+	}
+	return Token{Value: value, Type: Identifier, Line: line, Column: column}, nil
 }
@@ -0,0 +1,35 @@
+package ged
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchmarkProgram generates a program of about 170 KB mixing the kinds of
+// token the lexer reads: keywords, names, numbers, operators, strings with
+// and without escapes, and comments.
+func benchmarkProgram() string {
+	var b strings.Builder
+	for i := 0; b.Len() < 170_000; i++ {
+		fmt.Fprintf(&b, "let value%d = %d + %d.5 * (x - 0x%x) / 2;\n", i, i, i, i)
+		fmt.Fprintf(&b, "let greet%d name = printf \"Hello, %%s! #%d\\n\" name; // greeting\n", i, i)
+		fmt.Fprintf(&b, "println (if value%d >= 10 && true then \"big\" else \"small\");\n", i)
+		fmt.Fprintf(&b, "let r%d = {a: [1, 2, 3], b: \"plain text\"}.a;\n", i)
+	}
+	return b.String()
+}
+
+// BenchmarkTokenize measures lexing a whole program. Run it with
+//
+//	go test -run '^$' -bench Tokenize -benchmem
+func BenchmarkTokenize(b *testing.B) {
+	src := benchmarkProgram()
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := NewLexer(src).Tokenize(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
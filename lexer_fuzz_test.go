@@ -0,0 +1,54 @@
+package ged
+
+import (
+	"errors"
+	"testing"
+)
+
+// FuzzTokenize checks that the lexer never panics, whatever the input, and
+// that it either returns tokens ending in Eof or fails with a *LexError.
+// The seeds below and those in testdata/fuzz/FuzzTokenize run with every
+// go test; to search for new failures, run
+//
+//	go test -run '^$' -fuzz FuzzTokenize -fuzztime 60s
+//
+// Any input that fails is saved under testdata/fuzz/FuzzTokenize, and is
+// then run as a seed from then on.
+func FuzzTokenize(f *testing.F) {
+	for _, seed := range []string{
+		// the sample program run by cmd/ged
+		"println (420 + 69);\nlet sayHello name = printf \"Hi, %s!\\n\" name;\nsayHello \"world\";\n",
+		// unterminated strings, chars, comments and interpolations
+		`"abc`, `"abc\`, `"a${`, `"a${1`, `"${"${x}"`, `'`, `'\`, `"\u{`, "/* open", "\"a\\\n",
+		// stray runes
+		"@", "#~`", "|", "\x00", "\xff\xfe", "😀", "x́", "{ ] }", ")",
+		// huge and malformed numbers
+		"99999999999999999999999999", "1e999999", "0x", "0xffffffffffffffffffff", "1__2", "1..2", "5.", ".5", ".",
+		// newline mode
+		"let x = 1\nx\n|> f\n", "f (\n1\n)\n",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		for _, mode := range []Mode{ModeFreeForm, ModeNewline} {
+			l := NewLexer(src)
+			l.Mode = mode
+			tokens, err := l.Tokenize()
+			if err != nil {
+				var lexErr *LexError
+				if !errors.As(err, &lexErr) {
+					t.Fatalf("Tokenize(%q) failed with %T, not *LexError: %v", src, err, err)
+				}
+				continue
+			}
+			if len(tokens) == 0 || tokens[len(tokens)-1].Type != Eof {
+				t.Fatalf("Tokenize(%q) = %v, which does not end in Eof", src, tokens)
+			}
+			for _, tok := range tokens {
+				if tok.Offset < 0 || tok.Offset > tok.End || tok.End > len(src) {
+					t.Fatalf("Tokenize(%q): %v has offsets %d:%d outside the input", src, tok, tok.Offset, tok.End)
+				}
+			}
+		}
+	})
+}
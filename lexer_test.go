@@ -0,0 +1,1010 @@
+package ged
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// sample is the program cmd/ged tokenized before it could run programs.
+const sample = `
+			println + 420 69;
+			let sayHello a b = printf "Hi, %s!" a;
+			sayHello "world";
+		`
+
+// lex tokenizes src, failing the test if it does not lex, and returns the
+// tokens before the final Eof.
+func lex(t *testing.T, src string) []Token {
+	t.Helper()
+	return lexWith(t, NewLexer(src))
+}
+
+// lexWith is lex for a lexer that has been configured.
+func lexWith(t *testing.T, l *Lexer) []Token {
+	t.Helper()
+	tokens, err := l.Tokenize()
+	if err != nil {
+		t.Fatalf("%q: %v", l.Input, err)
+	}
+	if len(tokens) == 0 || tokens[len(tokens)-1].Type != Eof {
+		t.Fatalf("%q: got %v, want tokens ending with eof", l.Input, tokens)
+	}
+	return tokens[:len(tokens)-1]
+}
+
+// values lists the value of each token.
+func values(tokens []Token) []string {
+	out := make([]string, len(tokens))
+	for i, tok := range tokens {
+		out[i] = tok.Value
+	}
+	return out
+}
+
+// tokenTypes lists the type of each token.
+func tokenTypes(tokens []Token) []TokenType {
+	out := make([]TokenType, len(tokens))
+	for i, tok := range tokens {
+		out[i] = tok.Type
+	}
+	return out
+}
+
+// positions describes each token as its value and line:column.
+func positions(tokens []Token) []string {
+	out := make([]string, len(tokens))
+	for i, tok := range tokens {
+		out[i] = fmt.Sprintf("%s %d:%d", tok.Value, tok.Line, tok.Column)
+	}
+	return out
+}
+
+func TestTokenPositions(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []string
+	}{
+		{"let x = 1;", []string{"let 1:1", "x 1:5", "= 1:7", "1 1:9", "; 1:10"}},
+		{"let x = \"héllo\";\n  y + 1;\nñ = 2 ;", []string{
+			"let 1:1", "x 1:5", "= 1:7", "héllo 1:9", "; 1:16",
+			"y 2:3", "+ 2:5", "1 2:7", "; 2:8",
+			"ñ 3:1", "= 3:3", "2 3:5", "; 3:7",
+		}},
+		{sample, []string{
+			"println 2:4", "+ 2:12", "420 2:14", "69 2:18", "; 2:20",
+			"let 3:4", "sayHello 3:8", "a 3:17", "b 3:19", "= 3:21", "printf 3:23", "Hi, %s! 3:30", "a 3:40", "; 3:41",
+			"sayHello 4:4", "world 4:13", "; 4:20",
+		}},
+		{"a\n  != b", []string{"a 1:1", "!= 2:3", "b 2:6"}},
+		{"a //\nb /* \n */ c", []string{"a 1:1", "b 2:1", "c 3:5"}},
+		{"x = \"\"\"one\n  two\nthree\"\"\" y", []string{"x 1:1", "= 1:3", "one\n  two\nthree 1:5", "y 3:10"}},
+		{"\"a\\\nb\" x", []string{"ab 1:1", "x 2:4"}},
+	} {
+		if got := positions(lex(t, tt.src)); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestTokenTypes(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []TokenType
+	}{
+		{"a * b / c % d", []TokenType{Identifier, Asterisk, Identifier, Slash, Identifier, Percent, Identifier}},
+		{"a/b", []TokenType{Identifier, Slash, Identifier}},
+		{"a /", []TokenType{Identifier, Slash}},
+		{"a == b", []TokenType{Identifier, Equal, Identifier}},
+		{"a = b", []TokenType{Identifier, Eq, Identifier}},
+		{"x <= y", []TokenType{Identifier, LessEqual, Identifier}},
+		{"p != q", []TokenType{Identifier, NotEqual, Identifier}},
+		{"a<b>c>=d", []TokenType{Identifier, Less, Identifier, Greater, Identifier, GreaterEqual, Identifier}},
+		{"a =", []TokenType{Identifier, Eq}},
+		{"f (a + b)", []TokenType{Identifier, LParen, Identifier, Plus, Identifier, RParen}},
+		{"(1)", []TokenType{LParen, IntNumber, RParen}},
+		{`sayHello("world")`, []TokenType{Identifier, LParen, Str, RParen}},
+		{"42 3.14 .5 5.", []TokenType{IntNumber, FloatNumber, FloatNumber, FloatNumber}},
+		{"0x1F 0o17 0b1010", []TokenType{IntNumber, IntNumber, IntNumber}},
+		{"1e10 2.5e-3 6.022E23 7e+2", []TokenType{FloatNumber, FloatNumber, FloatNumber, FloatNumber}},
+		{"true false trueValue trueish falsely", []TokenType{Boolean, Boolean, Identifier, Identifier, Identifier}},
+		{"let if then else ifold elsewhere lets", []TokenType{Let, If, Then, Else, Identifier, Identifier, Identifier}},
+		{"1, 2, 3", []TokenType{IntNumber, Comma, IntNumber, Comma, IntNumber}},
+		{"f(a,b,c)", []TokenType{Identifier, LParen, Identifier, Comma, Identifier, Comma, Identifier, RParen}},
+		{"a - b", []TokenType{Identifier, Minus, Identifier}},
+		{"a -> b", []TokenType{Identifier, Arrow, Identifier}},
+		{"a->b", []TokenType{Identifier, Arrow, Identifier}},
+		{"a -", []TokenType{Identifier, Minus}},
+		{"a - > b", []TokenType{Identifier, Minus, Greater, Identifier}},
+		{"1abc", []TokenType{IntNumber, Identifier}},
+		{"!x != y", []TokenType{Bang, Identifier, NotEqual, Identifier}},
+		{"a ! b", []TokenType{Identifier, Bang, Identifier}},
+		{"x:Int", []TokenType{Identifier, Colon, Identifier}},
+		{"x : Int", []TokenType{Identifier, Colon, Identifier}},
+		{"a::b", []TokenType{Identifier, Colon, Colon, Identifier}},
+		{"[1,2]", []TokenType{LBracket, IntNumber, Comma, IntNumber, RBracket}},
+		{"{ x }", []TokenType{LBrace, Identifier, RBrace}},
+		{"f[x]{y}", []TokenType{Identifier, LBracket, Identifier, RBracket, LBrace, Identifier, RBrace}},
+		{"a&&b || c", []TokenType{Identifier, And, Identifier, Or, Identifier}},
+		{"a->b<=c==d/e//x\n/**/f", []TokenType{Identifier, Arrow, Identifier, LessEqual, Identifier, Equal, Identifier, Slash, Identifier, Identifier}},
+		{"a.b", []TokenType{Identifier, Dot, Identifier}},
+		{".5", []TokenType{FloatNumber}},
+		{"a.5", []TokenType{Identifier, Dot, IntNumber}},
+		{"a .5", []TokenType{Identifier, FloatNumber}},
+		{"p.x.y", []TokenType{Identifier, Dot, Identifier, Dot, Identifier}},
+		{"(f x).y", []TokenType{LParen, Identifier, Identifier, RParen, Dot, Identifier}},
+		{"1.5 + .25", []TokenType{FloatNumber, Plus, FloatNumber}},
+		{"x1.5", []TokenType{Identifier, Dot, IntNumber}},
+		{".", []TokenType{Dot}},
+		{"a**b * c", []TokenType{Identifier, Power, Identifier, Asterisk, Identifier}},
+		{"a***b", []TokenType{Identifier, Power, Asterisk, Identifier}},
+		{"let x = 1 in x", []TokenType{Let, Identifier, Eq, IntNumber, In, Identifier}},
+		{"in_ inx", []TokenType{Identifier, Identifier}},
+		{"a|>b |> c", []TokenType{Identifier, Pipe, Identifier, Pipe, Identifier}},
+		{"a || b", []TokenType{Identifier, Or, Identifier}},
+		{"a+=b-=c*=d/=e", []TokenType{Identifier, PlusEq, Identifier, MinusEq, Identifier, AsteriskEq, Identifier, SlashEq, Identifier}},
+		{"+ = - > -> ** *= / = == //x", []TokenType{Plus, Eq, Minus, Greater, Arrow, Power, AsteriskEq, Slash, Eq, Equal}},
+	} {
+		if got := tokenTypes(lex(t, tt.src)); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{`"a\tb"`, "a\tb"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"x\\"`, `x\`},
+		{`"line\nbreak\r"`, "line\nbreak\r"},
+		{"\"\"\"one\n  \"two\" \\n 'q'\nthree\"\"\"", "one\n  \"two\" \\n 'q'\nthree"},
+		{`""""""`, ""},
+		{`"""a\tb"""`, `a\tb`},
+		{`"\x41"`, "A"},
+		{`"\x41\x42c"`, "ABc"},
+		{`"\u{1F600}"`, "😀"},
+		{`"a\u{e9}b"`, "aéb"},
+		{`"\u{0}"`, "\x00"},
+		{`"\u{10FFFF}"`, "\U0010FFFF"},
+		{"\"abc\\\ndef\"", "abcdef"},
+		{"\"abc\\\r\ndef\"", "abcdef"},
+		{"\"abc\\\rdef\"", "abcdef"},
+		{"\"a\\\n  b\"", "a  b"},
+		{"\"a\\\n\\\nb\"", "ab"},
+	} {
+		tokens := lex(t, tt.src)
+		if len(tokens) != 1 || tokens[0].Type != Str || tokens[0].Value != tt.want {
+			t.Errorf("%s: got %v, want the string %q", tt.src, tokens, tt.want)
+		}
+	}
+}
+
+func TestCharLiterals(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"'a'", "a"},
+		{"'é'", "é"},
+		{`'\n'`, "\n"},
+		{`'\''`, "'"},
+		{`'\\'`, "\\"},
+		{`'"'`, `"`},
+		{`'\x41'`, "A"},
+		{`'\u{1F600}'`, "😀"},
+	} {
+		got := lex(t, tt.src)
+		if len(got) != 1 || got[0].Type != CharLiteral || got[0].Value != tt.want {
+			t.Errorf("%s: got %v, want the character %q", tt.src, got, tt.want)
+		}
+	}
+	if got := positions(lex(t, `'a' x' 'b'`)); !slices.Equal(got, []string{"a 1:1", "x' 1:5", "b 1:8"}) {
+		t.Errorf("got %q, want a primed identifier between two characters", got)
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []string
+	}{
+		{"42 3.14 .5 5.", []string{"42", "3.14", ".5", "5."}},
+		{"0x1F 0o17 0b1010 0 07;", []string{"0x1F", "0o17", "0b1010", "0", "07", ";"}},
+		{"0xff+0b1", []string{"0xff", "+", "0b1"}},
+		{"1e10 2.5e-3 6.022E23 7e+2;", []string{"1e10", "2.5e-3", "6.022E23", "7e+2", ";"}},
+		{"1_000 1_000.000_1 0xff_ff 1e1_0", []string{"1000", "1000.0001", "0xffff", "1e10"}},
+	} {
+		if got := values(lex(t, tt.src)); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []string
+	}{
+		{"counter1 x' _private x''", []string{"counter1", "x'", "_private", "x''"}},
+		{"a'b", []string{"a'", "b"}},
+		{"_foo __x", []string{"_foo", "__x"}},
+		{"привет_мир1", []string{"привет_мир1"}},
+		{"caf\u00e9 cafe\u0301", []string{"caf\u00e9", "cafe\u0301"}},
+		{"Ⅻ x‿y _٣", []string{"Ⅻ", "x‿y", "_٣"}},
+	} {
+		tokens := lex(t, tt.src)
+		if got := values(tokens); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+		for _, tok := range tokens {
+			if tok.Type != Identifier {
+				t.Errorf("%q: %v is not an identifier", tt.src, tok)
+			}
+		}
+	}
+}
+
+// TestUnderscore checks that only a lone _ is the wildcard.
+func TestUnderscore(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []TokenType
+	}{
+		{"_", []TokenType{Underscore}},
+		{"_x", []TokenType{Identifier}},
+		{"x_", []TokenType{Identifier}},
+		{"__ a_b _'", []TokenType{Identifier, Identifier, Identifier}},
+		{"let _ = f _x;", []TokenType{Let, Underscore, Eq, Identifier, Identifier, Semicolon}},
+	} {
+		if got := tokenTypes(lex(t, tt.src)); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+// TestDecimalPoints checks which dots belong to a number: a float may
+// leave out the digits on one side of the point, but not on both.
+func TestDecimalPoints(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []string
+	}{
+		{"1.", []string{"floatNumber 1."}},
+		{".5", []string{"floatNumber .5"}},
+		{"1.foo", []string{"floatNumber 1.", "identifier foo"}},
+		{".", []string{"dot ."}},
+		{". 5", []string{"dot .", "intNumber 5"}},
+		{"x.5", []string{"identifier x", "dot .", "intNumber 5"}},
+		{"[1].", []string{"lbracket [", "intNumber 1", "rbracket ]", "dot ."}},
+	} {
+		var got []string
+		for _, tok := range lex(t, tt.src) {
+			got = append(got, tok.Type.String()+" "+tok.Value)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestInterpolationTokens(t *testing.T) {
+	for _, tt := range []struct {
+		src   string
+		types []TokenType
+		want  []string
+	}{
+		{`"Hi, ${name}!"`, []TokenType{InterpStart, Identifier, InterpEnd}, []string{"Hi, ", "name", "!"}},
+		{`"${a + b}"`, []TokenType{InterpStart, Identifier, Plus, Identifier, InterpEnd}, []string{"", "a", "+", "b", ""}},
+		{`"${a}-${b}"`, []TokenType{InterpStart, Identifier, InterpMiddle, Identifier, InterpEnd}, []string{"", "a", "-", "b", ""}},
+		{`"cost: $5 $ {x}"`, []TokenType{Str}, []string{"cost: $5 $ {x}"}},
+		{`"\${x}"`, []TokenType{Str}, []string{"${x}"}},
+		{`"a${f({a})}b"`, []TokenType{InterpStart, Identifier, LParen, LBrace, Identifier, RBrace, RParen, InterpEnd}, []string{"a", "f", "(", "{", "a", "}", ")", "b"}},
+		{`"${g("}")}"`, []TokenType{InterpStart, Identifier, LParen, Str, RParen, InterpEnd}, []string{"", "g", "(", "}", ")", ""}},
+		{`"${ {"${ {x} }"} }!"`, []TokenType{InterpStart, LBrace, InterpStart, LBrace, Identifier, RBrace, InterpEnd, RBrace, InterpEnd}, []string{"", "{", "", "{", "x", "}", "", "}", "!"}},
+		{`{ "${x}" }`, []TokenType{LBrace, InterpStart, Identifier, InterpEnd, RBrace}, []string{"{", "", "x", "", "}"}},
+	} {
+		tokens := lex(t, tt.src)
+		if got := tokenTypes(tokens); !slices.Equal(got, tt.types) {
+			t.Errorf("%s: got %v, want %v", tt.src, got, tt.types)
+		}
+		if got := values(tokens); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeEndOfInput(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []string
+	}{
+		{"", []string{}},
+		{"  \n ", []string{}},
+		{"let x = 1 + foo", []string{"let", "x", "=", "1", "+", "foo"}},
+		{"x = 42", []string{"x", "=", "42"}},
+		{"say \"hi\";", []string{"say", "hi", ";"}},
+		{"a // hi\nb", []string{"a", "b"}},
+		{"a /* x * / y \n */ b", []string{"a", "b"}},
+		{"a / b // end", []string{"a", "/", "b"}},
+		{"a /**/", []string{"a"}},
+		{"a //", []string{"a"}},
+	} {
+		if got := values(lex(t, tt.src)); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeEndsWithEof(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want string
+	}{
+		{"", "1:1"},
+		{"x", "1:2"},
+		{"let x = 1;", "1:11"},
+		{"a\n", "2:1"},
+		{"a // end", "1:9"},
+		{"héllo", "1:6"},
+	} {
+		tokens, err := NewLexer(tt.src).Tokenize()
+		if err != nil {
+			t.Fatalf("%q: %v", tt.src, err)
+		}
+		last := tokens[len(tokens)-1]
+		if got := fmt.Sprintf("%d:%d", last.Line, last.Column); last.Type != Eof || last.Value != "" || got != tt.want {
+			t.Errorf("%q: last token is %v at %s, want eof at %s", tt.src, last, got, tt.want)
+		}
+		for _, tok := range tokens[:len(tokens)-1] {
+			if tok.Type == Eof {
+				t.Errorf("%q: eof before the last token", tt.src)
+			}
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src     string
+		want    error
+		message string
+	}{
+		{"x \"abc", ErrUnterminatedString, "lex error at line 1, col 3: unterminated string literal"},
+		{"x\n  \"abc", ErrUnterminatedString, "lex error at line 2, col 3: unterminated string literal"},
+		{"x\n  \"abc\\", ErrUnterminatedString, "lex error at line 2, col 3: unterminated string literal"},
+		{"x @", UnknownTokenError, "lex error at line 1, col 3: unknown token '@'"},
+		{"let x = 1;\nlet @ = 2;", UnknownTokenError, "lex error at line 2, col 5: unknown token '@'"},
+		{"a\n /* never", ErrUnterminatedComment, "lex error at line 2, col 2: unterminated block comment"},
+		{`"abc\`, ErrUnterminatedString, "lex error at line 1, col 1: unterminated string literal"},
+		{`"ab\q"`, ErrUnknownEscape, `lex error at line 1, col 4: unknown escape sequence '\q'`},
+		{"x 1.2.3", ErrMalformedNumber, `lex error at line 1, col 3: malformed number "1.2.3": too many decimal points`},
+		{"0x", ErrMalformedNumber, `lex error at line 1, col 1: malformed number "0x": missing digits`},
+		{"_1", ErrMalformedNumber, `lex error at line 1, col 1: malformed number "_1": leading underscore`},
+		{"1_", ErrMalformedNumber, `lex error at line 1, col 1: malformed number "1_": trailing underscore`},
+		{"1__0", ErrMalformedNumber, `lex error at line 1, col 1: malformed number "1__0": repeated underscore`},
+		{"1._5", ErrMalformedNumber, `lex error at line 1, col 1: malformed number "1._5": underscore must follow a digit`},
+		{"1e", ErrMalformedNumber, `lex error at line 1, col 1: malformed number "1e": missing exponent digits`},
+		{"x 1e+;", ErrMalformedNumber, `lex error at line 1, col 3: malformed number "1e+": missing exponent digits`},
+		{"0b12", ErrInvalidDigit, "lex error at line 1, col 4: invalid digit '2' in number literal"},
+		{"0o8;", ErrInvalidDigit, "lex error at line 1, col 3: invalid digit '8' in number literal"},
+		{"x\n \"\"\"abc\n\"\"", ErrUnterminatedString, "lex error at line 2, col 2: unterminated string literal"},
+		{"x = \"a ${b", ErrUnterminatedString, "lex error at line 1, col 8: unterminated interpolation"},
+		{"x = \"a ${b} c", ErrUnterminatedString, "lex error at line 1, col 5: unterminated string literal"},
+		{"a & b", UnknownTokenError, "lex error at line 1, col 3: unknown token '&'"},
+		{"a | b", UnknownTokenError, "lex error at line 1, col 3: unknown token '|'"},
+		{"a |", UnknownTokenError, "lex error at line 1, col 3: unknown token '|'"},
+		{"''", ErrInvalidChar, "lex error at line 1, col 1: empty character literal"},
+		{"'ab'", ErrInvalidChar, "lex error at line 1, col 1: character literal holds more than one character"},
+		{`'\n\t'`, ErrInvalidChar, "lex error at line 1, col 1: character literal holds more than one character"},
+		{"x 'a", ErrInvalidChar, "lex error at line 1, col 3: unterminated character literal"},
+		{"'a\n'", ErrInvalidChar, "lex error at line 1, col 1: unterminated character literal"},
+		{`'\`, ErrInvalidChar, "lex error at line 1, col 1: unterminated character literal"},
+		{`'\q'`, ErrUnknownEscape, `lex error at line 1, col 2: unknown escape sequence '\q'`},
+		{"x\n  \"ab ${ f {", ErrUnterminatedString, "lex error at line 2, col 7: unterminated interpolation"},
+		{"\"${ {x}", ErrUnterminatedString, "lex error at line 1, col 2: unterminated interpolation"},
+		{"9223372036854775808", ErrNumberRange, "lex error at line 1, col 1: integer literal 9223372036854775808 overflows int64"},
+		{"x =\n  0xFFFF_FFFF_FFFF_FFFF;", ErrNumberRange, "lex error at line 2, col 3: integer literal 0xFFFF_FFFF_FFFF_FFFF overflows int64"},
+		{"1e400", ErrNumberRange, "lex error at line 1, col 1: float literal 1e400 overflows float64"},
+		{`"\u{110000}"`, ErrInvalidEscape, `lex error at line 1, col 2: invalid escape sequence '\u{110000}': U+110000 is not a valid code point`},
+		{`"ab\u{D800}"`, ErrInvalidEscape, `lex error at line 1, col 4: invalid escape sequence '\u{D800}': U+D800 is not a valid code point`},
+		{`"\x4g"`, ErrInvalidEscape, `lex error at line 1, col 2: invalid escape sequence '\x4': \x must be followed by two hex digits`},
+		{`"\x80"`, ErrInvalidEscape, `lex error at line 1, col 2: invalid escape sequence '\x80': \x escapes stop at \x7f, use \u{80}`},
+		{`"\u41"`, ErrInvalidEscape, `lex error at line 1, col 2: invalid escape sequence '\u': \u must be followed by {hex digits}`},
+		{`"\u{}"`, ErrInvalidEscape, `lex error at line 1, col 2: invalid escape sequence '\u{}': missing hex digits`},
+		{`"\u{1234567}"`, ErrInvalidEscape, `lex error at line 1, col 2: invalid escape sequence '\u{123456': expected one to six hex digits and a closing }`},
+		{`'\u{110000}'`, ErrInvalidEscape, `lex error at line 1, col 2: invalid escape sequence '\u{110000}': U+110000 is not a valid code point`},
+		{`"\x4`, ErrUnterminatedString, "lex error at line 1, col 1: unterminated string literal"},
+		{`"\u{12`, ErrUnterminatedString, "lex error at line 1, col 1: unterminated string literal"},
+		{"\"abc\\\n", ErrUnterminatedString, "lex error at line 1, col 1: unterminated string literal"},
+		{"'\\\n'", ErrUnknownEscape, "lex error at line 1, col 2: unknown escape sequence '\\\n'"},
+		{"1..2", ErrMalformedNumber, `lex error at line 1, col 1: malformed number "1..2": too many decimal points`},
+		{"😀", UnknownTokenError, "lex error at line 1, col 1: unknown token '😀'"},
+		{"x😀", UnknownTokenError, "lex error at line 1, col 2: unknown token '😀'"},
+		{"let a = b★;", UnknownTokenError, "lex error at line 1, col 10: unknown token '★'"},
+		{"\u0301x", UnknownTokenError, "lex error at line 1, col 1: unknown token '\u0301'"},
+	} {
+		_, err := NewLexer(tt.src).Tokenize()
+		var lexErr *LexError
+		if !errors.As(err, &lexErr) || !errors.Is(err, tt.want) || errors.Is(err, EOF) || err.Error() != tt.message {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.message)
+		}
+	}
+}
+
+func TestNextMatchesTokenize(t *testing.T) {
+	for _, src := range []string{sample, "", "a // end", "f(1, 2.5) -> x != \"s\""} {
+		want := lex(t, src)
+		l := NewLexer(src)
+		var got []Token
+		for {
+			tok, err := l.Next()
+			if errors.Is(err, EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%q: %v", src, err)
+			}
+			got = append(got, tok)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%q: Next gave %v, Tokenize gave %v", src, got, want)
+		}
+		if _, err := l.Next(); !errors.Is(err, EOF) {
+			t.Errorf("%q: Next after the end gave error %v, want EOF", src, err)
+		}
+	}
+}
+
+func TestTokenTypeString(t *testing.T) {
+	for typ, want := range map[TokenType]string{
+		Let:           "let",
+		If:            "if",
+		Then:          "then",
+		Else:          "else",
+		Identifier:    "identifier",
+		IntNumber:     "intNumber",
+		FloatNumber:   "floatNumber",
+		Str:           "str",
+		Boolean:       "boolean",
+		Plus:          "plus",
+		Minus:         "minus",
+		Asterisk:      "asterisk",
+		Slash:         "slash",
+		Percent:       "percent",
+		Eq:            "eq",
+		Equal:         "equal",
+		NotEqual:      "notEqual",
+		Less:          "less",
+		LessEqual:     "lessEqual",
+		Greater:       "greater",
+		GreaterEqual:  "greaterEqual",
+		Semicolon:     "semicolon",
+		LParen:        "lparen",
+		RParen:        "rparen",
+		Comma:         "comma",
+		Arrow:         "arrow",
+		Bang:          "bang",
+		InterpStart:   "interpStart",
+		InterpMiddle:  "interpMiddle",
+		InterpEnd:     "interpEnd",
+		Colon:         "colon",
+		LBracket:      "lbracket",
+		RBracket:      "rbracket",
+		LBrace:        "lbrace",
+		RBrace:        "rbrace",
+		And:           "and",
+		Or:            "or",
+		CharLiteral:   "charLiteral",
+		Dot:           "dot",
+		Power:         "power",
+		In:            "in",
+		Comment:       "comment",
+		Underscore:    "underscore",
+		Pipe:          "pipe",
+		Newline:       "newline",
+		Where:         "where",
+		PlusEq:        "plusEq",
+		MinusEq:       "minusEq",
+		AsteriskEq:    "asteriskEq",
+		SlashEq:       "slashEq",
+		Eof:           "eof",
+		TokenType(-1): "TokenType(-1)",
+		TokenType(99): "TokenType(99)",
+	} {
+		if got := typ.String(); got != want {
+			t.Errorf("TokenType(%d).String() = %q, want %q", int(typ), got, want)
+		}
+	}
+	if got, want := (Token{Value: "x", Type: Identifier}).String(), "{identifier x}"; got != want {
+		t.Errorf("Token.String() = %q, want %q", got, want)
+	}
+}
+
+func TestLexErrorFields(t *testing.T) {
+	_, err := NewLexer("let x = 1;\nlet @ = 2;").Tokenize()
+	var lexErr *LexError
+	if !errors.As(err, &lexErr) {
+		t.Fatalf("got error %v, want a *LexError", err)
+	}
+	if lexErr.Lexeme != "@" || lexErr.Line != 2 || lexErr.Column != 5 || lexErr.Message != "unknown token '@'" {
+		t.Errorf("got %+v", *lexErr)
+	}
+}
+
+func TestReset(t *testing.T) {
+	l := NewLexer("a\nb \"open")
+	if _, err := l.Tokenize(); err == nil {
+		t.Fatal("Tokenize of an unterminated string succeeded")
+	}
+	for _, src := range []string{"x + 1", "let y =\n  2;", ""} {
+		l.Reset(src)
+		got, err := l.Tokenize()
+		if err != nil {
+			t.Fatalf("%q after Reset: %v", src, err)
+		}
+		if want, _ := NewLexer(src).Tokenize(); !slices.Equal(got, want) {
+			t.Errorf("%q after Reset: got %v, want %v", src, positions(got), positions(want))
+		}
+	}
+}
+
+func TestTokensJSON(t *testing.T) {
+	got, err := TokensJSON("let x = 1;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"value":"let","type":"let","line":1,"column":1},{"value":"x","type":"identifier","line":1,"column":5},{"value":"=","type":"eq","line":1,"column":7},{"value":"1","type":"intNumber","line":1,"column":9},{"value":";","type":"semicolon","line":1,"column":10},{"value":"","type":"eof","line":1,"column":11}]`
+	if string(got) != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+	if _, err := TokensJSON("@"); !errors.Is(err, UnknownTokenError) {
+		t.Errorf("TokensJSON(\"@\") gave error %v, want UnknownTokenError", err)
+	}
+}
+
+func TestTokenTypeText(t *testing.T) {
+	for _, typ := range []TokenType{Let, IntNumber, Arrow, InterpEnd} {
+		text, err := typ.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got TokenType
+		if err := got.UnmarshalText(text); err != nil || got != typ {
+			t.Errorf("%s read back as %v, %v", typ, got, err)
+		}
+	}
+	var typ TokenType
+	if err := typ.UnmarshalText([]byte("bogus")); err == nil {
+		t.Errorf("UnmarshalText(bogus) gave %v, want an error", typ)
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	unix := "let x = 1;\n// c\nlet y = \"a\nb\";\n\n  z; /* \n */ w"
+	want := lex(t, unix)
+	for _, eol := range []string{"\r\n", "\r"} {
+		src := strings.ReplaceAll(unix, "\n", eol)
+		got := lex(t, src)
+		if len(got) != len(want) {
+			t.Fatalf("%q: got %v, want %v", src, positions(got), positions(want))
+		}
+		for i := range want {
+			if got[i].Line != want[i].Line || got[i].Column != want[i].Column {
+				t.Errorf("%q: %v is at %d:%d, want %d:%d", src, got[i], got[i].Line, got[i].Column, want[i].Line, want[i].Column)
+			}
+		}
+		if str := got[8].Value; str != "a"+eol+"b" {
+			t.Errorf("%q: string holds %q, want the line break as written", src, str)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, tt := range []struct {
+		src                 string
+		maxBytes, maxTokens int
+		want                string
+	}{
+		{"a b c", 0, 3, ""},
+		{"a b c d", 0, 3, "lex error at line 1, col 7: more than 3 tokens"},
+		{"abcd", 4, 0, ""},
+		{"abcde", 4, 0, "lex error at line 1, col 1: input is 5 bytes, limit is 4"},
+		{"a b c d e f", 0, 0, ""},
+	} {
+		l := NewLexer(tt.src)
+		l.MaxInputBytes, l.MaxTokens = tt.maxBytes, tt.maxTokens
+		_, err := l.Tokenize()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%q with limits %d and %d: %v", tt.src, tt.maxBytes, tt.maxTokens, err)
+		case tt.want != "" && (!errors.Is(err, ErrInputTooLarge) || err.Error() != tt.want):
+			t.Errorf("%q with limits %d and %d: got error %v, want %s", tt.src, tt.maxBytes, tt.maxTokens, err, tt.want)
+		}
+	}
+}
+
+func TestLimitsSurviveReset(t *testing.T) {
+	l := NewLexer("a b")
+	l.MaxTokens = 2
+	if _, err := l.Tokenize(); err != nil {
+		t.Fatal(err)
+	}
+	l.Reset("x y z")
+	if _, err := l.Tokenize(); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("after Reset got error %v, want ErrInputTooLarge", err)
+	}
+}
+
+func TestTokenCategories(t *testing.T) {
+	keywords := []TokenType{Let, If, Then, Else, In, Where}
+	literals := []TokenType{IntNumber, FloatNumber, Str, InterpStart, InterpMiddle, InterpEnd, Boolean, CharLiteral}
+	operators := []TokenType{Plus, Minus, Asterisk, Slash, Percent, Bang, Eq, Equal, NotEqual, Less, LessEqual, Greater, GreaterEqual, And, Or, Arrow, Power, Pipe, PlusEq, MinusEq, AsteriskEq, SlashEq}
+	for typ := TokenType(0); int(typ) < len(tokenNames); typ++ {
+		want := "none"
+		switch {
+		case slices.Contains(keywords, typ):
+			want = "keyword"
+		case slices.Contains(literals, typ):
+			want = "literal"
+		case slices.Contains(operators, typ):
+			want = "operator"
+		}
+		var got []string
+		if typ.IsKeyword() {
+			got = append(got, "keyword")
+		}
+		if typ.IsLiteral() {
+			got = append(got, "literal")
+		}
+		if typ.IsOperator() {
+			got = append(got, "operator")
+		}
+		if len(got) == 0 {
+			got = append(got, "none")
+		}
+		if !slices.Equal(got, []string{want}) {
+			t.Errorf("%s: categories %v, want %s", typ, got, want)
+		}
+	}
+}
+
+func TestPeekAt(t *testing.T) {
+	l := NewLexer("aé->")
+	l.next()
+	for _, tt := range []struct {
+		n    int
+		want rune
+		err  error
+	}{
+		{0, 'é', nil},
+		{1, '-', nil},
+		{2, '>', nil},
+		{3, -1, EOF},
+		{10, -1, EOF},
+	} {
+		if r, err := l.peekAt(tt.n); r != tt.want || err != tt.err {
+			t.Errorf("peekAt(%d) = %q, %v, want %q, %v", tt.n, r, err, tt.want, tt.err)
+		}
+	}
+	if l.pos != 1 {
+		t.Errorf("peekAt moved the lexer to byte %d, want 1", l.pos)
+	}
+	if _, err := NewLexer("").peekAt(0); err != EOF {
+		t.Errorf("peekAt(0) of empty input gave error %v, want EOF", err)
+	}
+}
+
+// TestFreeFormMode checks that ModeFreeForm lexes every kind of white space
+// and comment exactly as the lexer did before modes existed.
+func TestFreeFormMode(t *testing.T) {
+	src := "\n\t\tprintln (420 + 69);\r\n  let f x = x // c\r  *\n2;\n/* b */ f\v1\f;"
+	want := []string{
+		`identifier "println" 2:3`, `lparen "(" 2:11`, `intNumber "420" 2:12`, `plus "+" 2:16`, `intNumber "69" 2:18`, `rparen ")" 2:20`, `semicolon ";" 2:21`,
+		`let "let" 3:3`, `identifier "f" 3:7`, `identifier "x" 3:9`, `eq "=" 3:11`, `identifier "x" 3:13`,
+		`asterisk "*" 4:3`,
+		`intNumber "2" 5:1`, `semicolon ";" 5:2`,
+		`identifier "f" 6:9`, `intNumber "1" 6:11`, `semicolon ";" 6:13`,
+		`eof "" 6:14`,
+	}
+	for _, l := range []*Lexer{NewLexer(src), {Input: src, Mode: ModeFreeForm}} {
+		tokens, err := l.Tokenize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, fmt.Sprintf("%s %q %d:%d", tok.Type, tok.Value, tok.Line, tok.Column))
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("mode %d: got %q, want %q", l.Mode, got, want)
+		}
+	}
+}
+
+func TestNewlineTokens(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []TokenType
+	}{
+		{"a + b\nc", []TokenType{Identifier, Plus, Identifier, Newline, Identifier, Newline}},
+		{"a +\n  b", []TokenType{Identifier, Plus, Identifier, Newline}},
+		{"a\n(b\n)\n", []TokenType{Identifier, Newline, LParen, Identifier, RParen, Newline}},
+		{"[1,\n 2]\r\nx", []TokenType{LBracket, IntNumber, Comma, IntNumber, RBracket, Newline, Identifier, Newline}},
+		{"x\n\n\ny", []TokenType{Identifier, Newline, Identifier, Newline}},
+		{"x\n  |> f", []TokenType{Identifier, Pipe, Identifier, Newline}},
+		{"x // c\ny", []TokenType{Identifier, Newline, Identifier, Newline}},
+		{"x;\n", []TokenType{Identifier, Semicolon}},
+		{"", nil},
+	} {
+		l := NewLexer(tt.src)
+		l.Mode = ModeNewline
+		if got := tokenTypes(lexWith(t, l)); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestTokensIterator(t *testing.T) {
+	var got []TokenType
+	for tok, err := range NewLexer("let x = 1;").Tokens() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, tok.Type)
+	}
+	if want := []TokenType{Let, Identifier, Eq, IntNumber, Semicolon, Eof}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var values []string
+	var errs []error
+	for tok, err := range NewLexer("a b @ c d").Tokens() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values = append(values, tok.Value)
+	}
+	if !slices.Equal(values, []string{"a", "b"}) || len(errs) != 1 || !errors.Is(errs[0], UnknownTokenError) {
+		t.Errorf("got %q and errors %v, want a and b, then one UnknownTokenError", values, errs)
+	}
+
+	l := NewLexer("a b c d")
+	for tok := range l.Tokens() {
+		if tok.Value == "b" {
+			break
+		}
+	}
+	if tok, err := l.Next(); err != nil || tok.Value != "c" {
+		t.Errorf("Next after stopping at b gave %v, %v, want c", tok, err)
+	}
+}
+
+func TestParsedValues(t *testing.T) {
+	tokens := lex(t, `42 0xf_f 1_000 2.5 1e3 .5 true false x "s"`)
+	want := []any{int64(42), int64(255), int64(1000), 2.5, 1000.0, 0.5, true, false, nil, nil}
+	for i, tok := range tokens {
+		if tok.Parsed != want[i] {
+			t.Errorf("%v: parsed as %#v, want %#v", tok, tok.Parsed, want[i])
+		}
+	}
+	if tokens := lex(t, "9223372036854775807 1e-400"); tokens[0].Parsed != int64(9223372036854775807) || tokens[1].Parsed != 0.0 {
+		t.Errorf("got %#v and %#v, want the largest int64 and 0", tokens[0].Parsed, tokens[1].Parsed)
+	}
+}
+
+func TestStrictNumberSuffix(t *testing.T) {
+	for _, tt := range []struct {
+		src    string
+		strict bool
+		want   []string
+		err    string
+	}{
+		{"420 foo", false, []string{"420", "foo", ""}, ""},
+		{"420 foo", true, []string{"420", "foo", ""}, ""},
+		{"420foo", false, []string{"420", "foo", ""}, ""},
+		{"420foo", true, nil, `lex error at line 1, col 4: invalid suffix "foo" after number 420`},
+		{"x = 1.5x;", true, nil, `lex error at line 1, col 8: invalid suffix "x" after number 1.5`},
+		{"1e3e", true, nil, `lex error at line 1, col 4: invalid suffix "e" after number 1e3`},
+		{"1_0abc", true, nil, `lex error at line 1, col 4: invalid suffix "abc" after number 1_0`},
+		{".5e2x", true, nil, `lex error at line 1, col 5: invalid suffix "x" after number .5e2`},
+		{"f xs[0] (1)+2 0x1f x1", true, []string{"f", "xs", "[", "0", "]", "(", "1", ")", "+", "2", "0x1f", "x1", ""}, ""},
+		{"1.foo", true, nil, `lex error at line 1, col 3: invalid suffix "foo" after number 1.`},
+	} {
+		l := NewLexer(tt.src)
+		l.Strict = tt.strict
+		tokens, err := l.Tokenize()
+		if tt.err != "" {
+			var lexErr *LexError
+			if !errors.As(err, &lexErr) || !errors.Is(err, ErrInvalidNumberSuffix) || err.Error() != tt.err {
+				t.Errorf("%q, strict %t: got error %v, want %s", tt.src, tt.strict, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q, strict %t: %v", tt.src, tt.strict, err)
+			continue
+		}
+		if got := values(tokens); !slices.Equal(got, tt.want) {
+			t.Errorf("%q, strict %t: got %q, want %q", tt.src, tt.strict, got, tt.want)
+		}
+	}
+}
+
+func TestKeepComments(t *testing.T) {
+	src := "// head\nlet x = 1; /* mid\n */ x // tail\r\n+ 2; /**/"
+	skipped := lex(t, src)
+	l := NewLexer(src)
+	l.KeepComments = true
+	kept, err := l.Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var comments, rest []Token
+	for _, tok := range kept[:len(kept)-1] {
+		if tok.Type == Comment {
+			comments = append(comments, tok)
+		} else {
+			rest = append(rest, tok)
+		}
+	}
+	if !slices.Equal(rest, skipped) {
+		t.Errorf("without comments, got %v, want %v", positions(rest), positions(skipped))
+	}
+	want := []string{"// head 1:1", "/* mid\n */ 2:12", "// tail 3:7", "/**/ 4:6"}
+	if got := positions(comments); !slices.Equal(got, want) {
+		t.Errorf("got comments %q, want %q", got, want)
+	}
+
+	l = NewLexer("a / b // c")
+	l.KeepComments = true
+	tokens, err := l.Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tokenTypes(tokens), []TokenType{Identifier, Slash, Identifier, Comment, Eof}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	l = NewLexer("1 /* open")
+	l.KeepComments = true
+	if _, err := l.Tokenize(); !errors.Is(err, ErrUnterminatedComment) {
+		t.Errorf("got error %v, want ErrUnterminatedComment", err)
+	}
+}
+
+func TestTargetIntBits(t *testing.T) {
+	for _, tt := range []struct {
+		bits int
+		src  string
+		ok   bool
+	}{
+		{0, "9223372036854775807", true},
+		{0, "99999999999999999999", false},
+		{64, "9223372036854775807", true},
+		{64, "9223372036854775808", false},
+		{32, "2147483647", true},
+		{32, "2147483648", false},
+		{32, "0x7fff_ffff", true},
+		{32, "0x80000000", false},
+		{32, "0b1" + strings.Repeat("0", 31), false},
+		{32, "1e20", true},
+	} {
+		l := NewLexer("x + " + tt.src)
+		l.TargetIntBits = tt.bits
+		_, err := l.Tokenize()
+		if tt.ok {
+			if err != nil {
+				t.Errorf("%d bits, %s: %v", tt.bits, tt.src, err)
+			}
+			continue
+		}
+		var lexErr *LexError
+		if !errors.As(err, &lexErr) || !errors.Is(err, ErrNumberRange) || lexErr.Line != 1 || lexErr.Column != 5 {
+			t.Errorf("%d bits, %s: got error %v, want ErrNumberRange at 1:5", tt.bits, tt.src, err)
+		}
+	}
+}
+
+func TestTokenOffsets(t *testing.T) {
+	src := "let é = \"a${x}\";// c\nx"
+	l := NewLexer(src)
+	l.KeepComments = true
+	tokens, err := l.Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, src[tok.Offset:tok.End])
+	}
+	want := []string{"let", "é", "=", `"a${`, "x", `}"`, ";", "// c", "x", ""}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTokenStats(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want map[TokenType]int
+	}{
+		{runSample, map[TokenType]int{Identifier: 6, LParen: 1, IntNumber: 2, Plus: 1, RParen: 1, Semicolon: 3, Let: 1, Eq: 1, Str: 2}},
+		{"x // c\n", map[TokenType]int{Identifier: 1}},
+		{"", map[TokenType]int{}},
+	} {
+		got, err := TokenStats(tt.src)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+	if _, err := TokenStats(`"x`); !errors.Is(err, ErrUnterminatedString) {
+		t.Errorf("got error %v, want ErrUnterminatedString", err)
+	}
+}
+
+func TestCustomKeywords(t *testing.T) {
+	kw := DefaultKeywords()
+	kw["fn"] = Let
+	kw["wenn"] = If
+	delete(kw, "where")
+	l := NewLexer("fn double n = n * 2;\nfn where = wenn true then 1 else 2;\nlet x = double where;")
+	l.Keywords = kw
+	program, err := NewParser(l).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := program.String(), "let double n = (n * 2);\nlet where = (if true then 1 else 2);\nlet x = (double where);"; got != want {
+		t.Errorf("parsed as\n%s\nwant\n%s", got, want)
+	}
+	if _, ok := DefaultKeywords()["fn"]; ok {
+		t.Error("changing a copy of the keyword table changed the defaults")
+	}
+	if got := tokenTypes(lex(t, "fn x = 1;")); got[0] != Identifier {
+		t.Errorf("fn lexed as %s by default, want an identifier", got[0])
+	}
+
+	// a synonym continues a line in ModeNewline as the word it stands for
+	l = NewLexer("x\n  sonst")
+	l.Mode = ModeNewline
+	l.Keywords = map[string]TokenType{"sonst": Else}
+	if got, want := tokenTypes(lexWith(t, l)), []TokenType{Identifier, Else}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestLeadingWhiteSpace checks that positions count the blank first line
+// and the tabs indenting the sample program cmd/ged runs.
+func TestLeadingWhiteSpace(t *testing.T) {
+	tokens := lex(t, runSample)
+	var line2 []Token
+	for _, tok := range tokens {
+		if tok.Line == 2 {
+			line2 = append(line2, tok)
+		}
+	}
+	want := []string{"println 2:4", "( 2:12", "420 2:13", "+ 2:17", "69 2:19", ") 2:21", "; 2:22"}
+	if got := positions(line2); !slices.Equal(got, want) {
+		t.Errorf("got line 2 tokens %q, want %q", got, want)
+	}
+
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"\r\n\t\tx", "x 2:3"},
+		{"\r\t\tx", "x 2:3"},
+		{"\n\n  \t x", "x 3:5"},
+	} {
+		if got := positions(lex(t, tt.src)); !slices.Equal(got, []string{tt.want}) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
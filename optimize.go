@@ -0,0 +1,245 @@
+package ged
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Fold replaces each operation whose operands are all literals with the
+// literal it evaluates to, following the evaluator's rules, so 2 + 3 * 4
+// becomes 14. Names and calls are left alone, as are results that have no
+// literal form such as infinity. A constant left operand of && or || that
+// decides the result replaces the whole operation, right operand and all,
+// just as the evaluator never runs it. The expression passed in is not
+// modified.
+func Fold(expr Expr) (Expr, error) {
+	switch n := expr.(type) {
+	case *UnaryExpr:
+		operand, err := Fold(n.Operand)
+		if err != nil {
+			return nil, err
+		}
+		folded := &UnaryExpr{nodeBase: n.nodeBase, Op: n.Op, Operand: operand}
+		if !isConstant(operand) {
+			return folded, nil
+		}
+		return foldConstant(folded, n.Op)
+	case *BinaryExpr:
+		left, err := Fold(n.Left)
+		if err != nil {
+			return nil, err
+		}
+		logical := n.Op.Type == And || n.Op.Type == Or
+		if b, ok := boolConstant(left); ok && logical && b == (n.Op.Type == Or) {
+			// the left operand decides, so the right one never runs
+			tok := literalToken(left)
+			return &BoolLiteral{nodeBase: n.nodeBase, Token: tok}, nil
+		}
+		right, err := Fold(n.Right)
+		if err != nil {
+			if !logical || isConstant(left) {
+				return nil, err
+			}
+			// whether the right operand runs depends on the left one, so
+			// any error is left for the evaluator to report if it does
+			right = n.Right
+		}
+		folded := &BinaryExpr{nodeBase: n.nodeBase, Op: n.Op, Left: left, Right: right}
+		if !isConstant(left) || !isConstant(right) {
+			return folded, nil
+		}
+		return foldConstant(folded, literalToken(left))
+	case *CallExpr:
+		callee, err := Fold(n.Callee)
+		if err != nil {
+			return nil, err
+		}
+		args, err := foldAll(n.Args)
+		if err != nil {
+			return nil, err
+		}
+		return &CallExpr{nodeBase: n.nodeBase, Token: n.Token, Callee: callee, Args: args}, nil
+	case *MemberExpr:
+		object, err := Fold(n.Object)
+		if err != nil {
+			return nil, err
+		}
+		return &MemberExpr{nodeBase: n.nodeBase, Token: n.Token, Object: object, Name: n.Name}, nil
+	case *LetInExpr:
+		parts, err := foldAll([]Expr{n.Value, n.Body})
+		if err != nil {
+			return nil, err
+		}
+		return &LetInExpr{nodeBase: n.nodeBase, Token: n.Token, Name: n.Name, Value: parts[0], Body: parts[1]}, nil
+	case *WhereExpr:
+		parts, err := foldAll(append([]Expr{n.Expr}, n.Values...))
+		if err != nil {
+			return nil, err
+		}
+		return &WhereExpr{nodeBase: n.nodeBase, Token: n.Token, Expr: parts[0], Names: n.Names, Values: parts[1:]}, nil
+	case *ListExpr:
+		elements, err := foldAll(n.Elements)
+		if err != nil {
+			return nil, err
+		}
+		return &ListExpr{nodeBase: n.nodeBase, Token: n.Token, Elements: elements}, nil
+	case *IndexExpr:
+		parts, err := foldAll([]Expr{n.Object, n.Index})
+		if err != nil {
+			return nil, err
+		}
+		return &IndexExpr{nodeBase: n.nodeBase, Token: n.Token, Object: parts[0], Index: parts[1]}, nil
+	case *RecordLiteral:
+		values, err := foldAll(n.Values)
+		if err != nil {
+			return nil, err
+		}
+		return &RecordLiteral{nodeBase: n.nodeBase, Token: n.Token, Keys: n.Keys, Values: values}, nil
+	case *BlockExpr:
+		block := &BlockExpr{nodeBase: n.nodeBase, Token: n.Token, Statements: make([]Statement, len(n.Statements))}
+		for i, st := range n.Statements {
+			folded, err := foldStatement(st)
+			if err != nil {
+				return nil, err
+			}
+			block.Statements[i] = folded
+		}
+		if n.Result != nil {
+			result, err := Fold(n.Result)
+			if err != nil {
+				return nil, err
+			}
+			block.Result = result
+		}
+		return block, nil
+	case *IfExpr:
+		parts, err := foldAll([]Expr{n.Cond, n.Then, n.Else})
+		if err != nil {
+			return nil, err
+		}
+		return &IfExpr{nodeBase: n.nodeBase, Token: n.Token, Cond: parts[0], Then: parts[1], Else: parts[2]}, nil
+	case *InterpStringExpr:
+		parts, err := foldAll(n.Parts)
+		if err != nil {
+			return nil, err
+		}
+		return &InterpStringExpr{nodeBase: n.nodeBase, Token: n.Token, Parts: parts}, nil
+	default:
+		return expr, nil
+	}
+}
+
+func foldStatement(st Statement) (Statement, error) {
+	switch s := st.(type) {
+	case *LetStatement:
+		value, err := Fold(s.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &LetStatement{nodeBase: s.nodeBase, Token: s.Token, Name: s.Name, Value: value}, nil
+	case *FunctionDef:
+		body, err := Fold(s.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &FunctionDef{nodeBase: s.nodeBase, Token: s.Token, Name: s.Name, Params: s.Params, Body: body}, nil
+	case *AssignStatement:
+		value, err := Fold(s.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &AssignStatement{nodeBase: s.nodeBase, Token: s.Token, Name: s.Name, Value: value}, nil
+	case *ExpressionStatement:
+		expr, err := Fold(s.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &ExpressionStatement{nodeBase: s.nodeBase, Expr: expr}, nil
+	}
+	return st, nil
+}
+
+func foldAll(exprs []Expr) ([]Expr, error) {
+	folded := make([]Expr, len(exprs))
+	for i, e := range exprs {
+		f, err := Fold(e)
+		if err != nil {
+			return nil, err
+		}
+		folded[i] = f
+	}
+	return folded, nil
+}
+
+// foldConstant evaluates expr, whose operands are all literals, into a
+// literal positioned at tok.
+func foldConstant(expr Expr, tok Token) (Expr, error) {
+	v, err := Eval(expr, NewEnvironment())
+	if err != nil {
+		var runtimeErr *RuntimeError
+		if errors.As(err, &runtimeErr) {
+			return nil, &CompileError{Line: runtimeErr.Line, Column: runtimeErr.Column, Message: runtimeErr.Message}
+		}
+		return nil, err
+	}
+	// the literal stands for all of expr in the source
+	base := nodeBase{span: expr.Span()}
+	switch v := v.(type) {
+	case IntValue:
+		tok.Type, tok.Value, tok.Parsed = IntNumber, v.String(), int64(v)
+		return &NumberLiteral{nodeBase: base, Token: tok}, nil
+	case FloatValue:
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return expr, nil
+		}
+		tok.Type, tok.Value, tok.Parsed = FloatNumber, v.String(), float64(v)
+		// keep the literal a float when the source is printed and lexed again
+		if !strings.ContainsAny(tok.Value, ".e") {
+			tok.Value += ".0"
+		}
+		return &NumberLiteral{nodeBase: base, Token: tok}, nil
+	case StringValue:
+		tok.Type, tok.Value, tok.Parsed = Str, string(v), nil
+		return &StringLiteral{nodeBase: base, Token: tok}, nil
+	case BoolValue:
+		tok.Type, tok.Value, tok.Parsed = Boolean, strconv.FormatBool(bool(v)), bool(v)
+		return &BoolLiteral{nodeBase: base, Token: tok}, nil
+	}
+	return expr, nil
+}
+
+func isConstant(e Expr) bool {
+	switch e.(type) {
+	case *NumberLiteral, *StringLiteral, *CharacterLiteral, *BoolLiteral:
+		return true
+	}
+	return false
+}
+
+// boolConstant returns the value of e if it is a bool literal.
+func boolConstant(e Expr) (bool, bool) {
+	lit, ok := e.(*BoolLiteral)
+	if !ok {
+		return false, false
+	}
+	if b, ok := lit.Token.Parsed.(bool); ok {
+		return b, true
+	}
+	return lit.Token.Value == "true", true
+}
+
+func literalToken(e Expr) Token {
+	switch n := e.(type) {
+	case *NumberLiteral:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *CharacterLiteral:
+		return n.Token
+	case *BoolLiteral:
+		return n.Token
+	}
+	return Token{}
+}
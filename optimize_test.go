@@ -0,0 +1,136 @@
+package ged
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"2 + 3 * 4", "14"},
+		{"10 / 4", "2.5"},
+		{"4 / 2", "2.0"},
+		{"1 + 2.5", "3.5"},
+		{"-5 + 3", "-2"},
+		{"x + 2 * 3", "(x + 6)"},
+		{"x * 2 + 3", "((x * 2) + 3)"},
+		{"f (1 + 2) x", "(f 3 x)"},
+		{"true && false", "false"},
+		{"if c then 1 + 1 else 2", "(if c then 2 else 2)"},
+		{`"a${1 + 2}"`, `"a${3}"`},
+		{"1e308 * 10", "(1e308 * 10)"},
+		{"{a: 1 + 1}.a", "({a: 2}.a)"},
+		{"{ let a = 1 + 2; a * (2 + 2) }", "{let a = 3; (a * 4)}"},
+		{"x * 2 ** 10", "(x * 1024)"},
+		{`"a" + "b"`, `"ab"`},
+		{`'a' + "b"`, `"ab"`},
+		{"[1 + 1][0 * 1]", "([2][0])"},
+		{"2 * 3 == 6", "true"},
+		{"let x = 2 * 3 in x + 1 * 2", "(let x = 6 in (x + 2))"},
+		{"false && (1 / 0 == 1.0)", "false"},
+		{"true || (1 % 0 == 1)", "true"},
+		{"true && 1 < 2", "true"},
+		{"false || x", "(false || x)"},
+		{"x && (1 / 0 == 1.0)", "(x && ((1 / 0) == 1.0))"},
+	} {
+		folded, err := Fold(parseExpr(t, tt.src))
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got := folded.String(); got != tt.want {
+			t.Errorf("%q: folded to %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestFoldKeepsPosition(t *testing.T) {
+	folded, err := Fold(parseExpr(t, "\n  2 + 3 * 4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, ok := folded.(*NumberLiteral)
+	if !ok || n.Token.Type != IntNumber || n.Token.Line != 2 || n.Token.Column != 3 {
+		t.Errorf("folded to %#v, want an int literal at 2:3", folded)
+	}
+}
+
+func TestFoldErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"x + 1 / (2 - 2)", "compile error at line 1, col 7: division by zero"},
+		{"f (1.5 / 0)", "compile error at line 1, col 8: division by zero"},
+		{`1 - "a"`, "compile error at line 1, col 3: unsupported operand types for -: int and string"},
+		{"true && (1 / 0 == 1.0)", "compile error at line 1, col 12: division by zero"},
+		{"false || 1 % 0 == 1", "compile error at line 1, col 12: modulo by zero"},
+		{"1 / 0 && x", "compile error at line 1, col 3: division by zero"},
+	} {
+		_, err := Fold(parseExpr(t, tt.src))
+		var foldErr *CompileError
+		if !errors.As(err, &foldErr) || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestFoldLeavesInputUnchanged(t *testing.T) {
+	expr := parseExpr(t, "1 + 2 * x + 3 * 4")
+	if _, err := Fold(expr); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expr.String(), "((1 + (2 * x)) + (3 * 4))"; got != want {
+		t.Errorf("after folding, the input reads %s, want %s", got, want)
+	}
+}
+
+func TestFoldSetsParsed(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want any
+	}{
+		{"2 * 3", int64(6)},
+		{"1 / 4", 0.25},
+		{"1 < 2", true},
+	} {
+		folded, err := Fold(parseExpr(t, tt.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parsed any
+		switch n := folded.(type) {
+		case *NumberLiteral:
+			parsed = n.Token.Parsed
+		case *BoolLiteral:
+			parsed = n.Token.Parsed
+		}
+		if parsed != tt.want {
+			t.Errorf("%q: folded to %s with Parsed %#v, want %#v", tt.src, folded, parsed, tt.want)
+		}
+	}
+}
+
+func TestFoldKeepsSpan(t *testing.T) {
+	src := "x = 1 + 2 * 3;"
+	program := parseProgram(t, src)
+	folded, err := Fold(program.Statements[0].(*AssignStatement).Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := folded.Source(src), "1 + 2 * 3"; got != want {
+		t.Errorf("folded literal spans %q, want %q", got, want)
+	}
+	built, err := Fold(&BinaryExpr{
+		Op:    Token{Type: Plus, Value: "+"},
+		Left:  &NumberLiteral{Token: Token{Type: IntNumber, Value: "1"}},
+		Right: &NumberLiteral{Token: Token{Type: IntNumber, Value: "2"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := built.Source(src); got != "" {
+		t.Errorf("folding a built tree gave a span over %q, want none", got)
+	}
+}
@@ -0,0 +1,813 @@
+package ged
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ParseError reports a syntax error at the position of the offending token.
+type ParseError struct {
+	Line     int
+	Column   int
+	Message  string
+	Expected []TokenType
+	Found    Token
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse error at line %d, col %d: %s", e.Line, e.Column, e.Message)
+}
+
+// ParseErrors collects every error found while parsing a program.
+type ParseErrors []error
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e ParseErrors) Unwrap() []error {
+	return e
+}
+
+// binding powers, loosest first
+const (
+	lowest = iota
+	pipe
+	logicalOr
+	logicalAnd
+	comparison
+	sum
+	product
+	power
+)
+
+var precedences = map[TokenType]int{
+	Pipe:         pipe,
+	Or:           logicalOr,
+	And:          logicalAnd,
+	Equal:        comparison,
+	NotEqual:     comparison,
+	Less:         comparison,
+	LessEqual:    comparison,
+	Greater:      comparison,
+	GreaterEqual: comparison,
+	Plus:         sum,
+	Minus:        sum,
+	Asterisk:     product,
+	Slash:        product,
+	Percent:      product,
+	Power:        power,
+}
+
+// rightAssociative holds the operators that group from the right, so
+// 2 ** 3 ** 2 is 2 ** (3 ** 2).
+var rightAssociative = map[TokenType]bool{
+	Power: true,
+}
+
+type Parser struct {
+	lexer *Lexer
+	// tokens and next are the input of a parser made by Parse instead of
+	// reading from a lexer
+	tokens []Token
+	next   int
+	kind   ParserKind
+	tok    Token
+	// err holds the lexing error that cut the token stream short, if any
+	err error
+	// endLine and endColumn are just past the last token consumed, where a
+	// missing token at the end of input is reported, and end is the byte
+	// offset there
+	endLine, endColumn int
+	end                int
+	// cur records where tok ends; ahead holds the token after it once peek
+	// has read it
+	cur    lookahead
+	ahead  lookahead
+	peeked bool
+	// Comments collects the comment tokens passed over so far, which only
+	// a lexer with KeepComments produces. The grammar never sees them.
+	Comments []Token
+}
+
+// lookahead is a token read from the lexer together with the position just
+// past its end.
+type lookahead struct {
+	tok                Token
+	endLine, endColumn int
+}
+
+func NewParser(l *Lexer) *Parser {
+	p := &Parser{lexer: l}
+	p.advance()
+	return p
+}
+
+func (p *Parser) advance() {
+	if p.tok.Type == Eof {
+		return
+	}
+	p.endLine, p.endColumn = p.cur.endLine, p.cur.endColumn
+	p.end = p.tok.End
+	if p.peeked {
+		p.cur, p.peeked = p.ahead, false
+	} else {
+		p.cur = p.read()
+	}
+	p.tok = p.cur.tok
+}
+
+// peek returns the token after the current one without consuming anything.
+func (p *Parser) peek() Token {
+	if p.tok.Type == Eof {
+		return p.tok
+	}
+	if !p.peeked {
+		p.ahead, p.peeked = p.read(), true
+	}
+	return p.ahead.tok
+}
+
+func (p *Parser) read() lookahead {
+	if p.lexer == nil {
+		return p.readSlice()
+	}
+	tok, err := p.lexer.Next()
+	for err == nil && tok.Type == Comment {
+		p.Comments = append(p.Comments, tok)
+		tok, err = p.lexer.Next()
+	}
+	if err != nil {
+		if !errors.Is(err, EOF) {
+			p.err = err
+		}
+		tok = p.lexer.eof()
+	}
+	line, column := p.lexer.position()
+	return lookahead{tok: tok, endLine: line, endColumn: column}
+}
+
+// readSlice is read for a parser over a slice of tokens. The position past
+// a token is worked out from its value, which is exact except for strings
+// and numbers not written in their plain form.
+func (p *Parser) readSlice() lookahead {
+	for p.next < len(p.tokens) && p.tokens[p.next].Type == Comment {
+		p.Comments = append(p.Comments, p.tokens[p.next])
+		p.next++
+	}
+	if p.next == len(p.tokens) {
+		// the slice may end without an Eof token, or be empty
+		tok := Token{Type: Eof, Line: 1, Column: 1}
+		if p.next > 0 {
+			last := p.tokens[p.next-1]
+			tok.Line, tok.Column = spanEnd(last.Line, last.Column, last.Value)
+			tok.Offset, tok.End = last.End, last.End
+		}
+		return lookahead{tok: tok, endLine: tok.Line, endColumn: tok.Column}
+	}
+	tok := p.tokens[p.next]
+	if tok.Type != Eof {
+		p.next++
+	}
+	line, column := spanEnd(tok.Line, tok.Column, tok.Value)
+	return lookahead{tok: tok, endLine: line, endColumn: column}
+}
+
+// ParseExpression parses the whole input as a single expression.
+func (p *Parser) ParseExpression() (Expr, error) {
+	expr, err := p.parseExpression(lowest)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.Type != Eof {
+		return nil, p.unexpected("end of input", Eof)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return expr, nil
+}
+
+// ParseProgram parses statements until the input is exhausted. Empty
+// statements, such as the second semicolon in `f x;;`, are skipped.
+//
+// A statement with a syntax error is dropped and parsing resumes after the
+// next semicolon, so the returned error is a ParseErrors holding every
+// problem found. The statements that did parse are returned alongside it.
+func (p *Parser) ParseProgram() (*Program, error) {
+	program := &Program{}
+	var errs ParseErrors
+	for p.tok.Type != Eof {
+		if isTerminator(p.tok.Type) {
+			p.advance()
+			continue
+		}
+		st, err := p.ParseStatement()
+		if err != nil {
+			errs = append(errs, err)
+			p.synchronize()
+			continue
+		}
+		program.Statements = append(program.Statements, st)
+	}
+	if p.err != nil && (len(errs) == 0 || errs[len(errs)-1] != p.err) {
+		errs = append(errs, p.err)
+	}
+	if len(errs) > 0 {
+		return program, errs
+	}
+	return program, nil
+}
+
+// synchronize skips past the next semicolon so parsing can resume at the
+// start of the following statement.
+func (p *Parser) synchronize() {
+	for p.tok.Type != Eof {
+		tok := p.tok
+		p.advance()
+		if isTerminator(tok.Type) {
+			return
+		}
+	}
+}
+
+// ParseStatement parses the next semicolon-terminated statement. Newlines
+// carry no meaning, so a statement may span as many lines as it likes,
+// unless the lexer is in ModeNewline.
+func (p *Parser) ParseStatement() (Statement, error) {
+	switch p.tok.Type {
+	case Let:
+		st, letIn, err := p.parseLet(false)
+		if err != nil || letIn == nil {
+			return st, err
+		}
+		return p.finishStatement(letIn.Span().Start, letIn)
+	default:
+		return p.parseExpressionStatement()
+	}
+}
+
+// parseExpressionStatement also parses assignments, which start out looking
+// like an expression consisting of a single name.
+func (p *Parser) parseExpressionStatement() (Statement, error) {
+	start := p.tok.Offset
+	expr, err := p.parseExpression(lowest)
+	if err != nil {
+		return nil, err
+	}
+	return p.finishStatement(start, expr)
+}
+
+// compoundAssignments maps each compound assignment to its operator.
+var compoundAssignments = map[TokenType]TokenType{
+	PlusEq:     Plus,
+	MinusEq:    Minus,
+	AsteriskEq: Asterisk,
+	SlashEq:    Slash,
+}
+
+func isAssignment(t TokenType) bool {
+	_, compound := compoundAssignments[t]
+	return t == Eq || compound
+}
+
+// finishStatement completes a statement that began with expr, either an
+// assignment or an expression statement, whose first token was at start. A
+// compound assignment such as x += 1 is rewritten into x = x + 1.
+func (p *Parser) finishStatement(start int, expr Expr) (Statement, error) {
+	if ident, ok := expr.(*Ident); ok && isAssignment(p.tok.Type) {
+		assign := p.tok
+		p.advance()
+		value, err := p.parseExpression(lowest)
+		if err != nil {
+			return nil, err
+		}
+		if op, ok := compoundAssignments[assign.Type]; ok {
+			assign.Type, assign.Value = op, strings.TrimSuffix(assign.Value, "=")
+			assign.End--
+			value = &BinaryExpr{nodeBase: p.spanFrom(start), Op: assign, Left: ident, Right: value}
+		}
+		if err := p.endStatement(); err != nil {
+			return nil, err
+		}
+		return &AssignStatement{nodeBase: p.spanFrom(start), Token: ident.Token, Name: ident.Name, Value: value}, nil
+	}
+	if err := p.endStatement(); err != nil {
+		return nil, err
+	}
+	return &ExpressionStatement{nodeBase: p.spanFrom(start), Expr: expr}, nil
+}
+
+// parseLet parses a let statement up to its semicolon, or a let ... in
+// expression, which is returned in place of the statement with whatever
+// follows it left unread. With inExpr set, only the expression is accepted.
+func (p *Parser) parseLet(inExpr bool) (Statement, Expr, error) {
+	let := p.tok
+	p.advance()
+	name := p.tok
+	if !isBinder(name.Type) {
+		return nil, nil, p.unexpected(Identifier.String(), Identifier, Underscore)
+	}
+	p.advance()
+	var params []string
+	for !inExpr && isBinder(p.tok.Type) {
+		params = append(params, p.tok.Value)
+		p.advance()
+	}
+	if _, err := p.expect(Eq); err != nil {
+		return nil, nil, err
+	}
+	value, err := p.parseExpression(lowest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if inExpr || p.tok.Type == In && len(params) == 0 {
+		if _, err := p.expect(In); err != nil {
+			return nil, nil, err
+		}
+		body, err := p.parseExpression(lowest)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &LetInExpr{nodeBase: p.spanFromToken(let), Token: let, Name: name.Value, Value: value, Body: body}, nil
+	}
+	if err := p.endStatement(); err != nil {
+		return nil, nil, err
+	}
+	if len(params) > 0 {
+		return &FunctionDef{nodeBase: p.spanFromToken(let), Token: let, Name: name.Value, Params: params, Body: value}, nil, nil
+	}
+	return &LetStatement{nodeBase: p.spanFromToken(let), Token: let, Name: name.Value, Value: value}, nil, nil
+}
+
+// endStatement consumes the semicolon or line break that ends a statement.
+// A line break is left out of the statement's span.
+func (p *Parser) endStatement() error {
+	if p.tok.Type == Newline {
+		end := p.end
+		p.advance()
+		p.end = end
+		return nil
+	}
+	_, err := p.expect(Semicolon)
+	return err
+}
+
+func isTerminator(t TokenType) bool {
+	return t == Semicolon || t == Newline
+}
+
+// spanFrom returns the span from start, the offset of the node's first
+// token, to the end of the last token consumed. The start is taken from the
+// token rather than from the node's first operand, whose span leaves out
+// any parentheses around it.
+func (p *Parser) spanFrom(start int) nodeBase {
+	return nodeBase{span: Span{start, p.end}}
+}
+
+// spanFromToken is spanFrom for a node that starts with tok.
+func (p *Parser) spanFromToken(tok Token) nodeBase {
+	return nodeBase{span: Span{tok.Offset, p.end}}
+}
+
+// isBinder reports whether t can be bound by a let or parameter: a name,
+// or _ to ignore the value.
+func isBinder(t TokenType) bool {
+	return t == Identifier || t == Underscore
+}
+
+// parseExpression parses an expression made of operators binding tighter
+// than minPrec. At the lowest precedence a where clause may follow.
+func (p *Parser) parseExpression(minPrec int) (Expr, error) {
+	if minPrec != lowest {
+		return p.parseBinary(minPrec)
+	}
+	start := p.tok.Offset
+	var expr Expr
+	var err error
+	if p.kind == RecursiveDescent {
+		expr, err = p.parsePipe()
+	} else {
+		expr, err = p.parseBinary(lowest)
+	}
+	if err != nil || p.tok.Type != Where {
+		return expr, err
+	}
+	return p.parseWhere(start, expr)
+}
+
+// parseWhere parses the definitions after the where that follows expr.
+// They are separated by commas, as a semicolon ends the statement, so a
+// where inside a list or record needs parentheses unless it comes last.
+func (p *Parser) parseWhere(start int, expr Expr) (Expr, error) {
+	where := &WhereExpr{Token: p.tok, Expr: expr}
+	p.advance()
+	for {
+		name := p.tok
+		if !isBinder(name.Type) {
+			return nil, p.unexpected(Identifier.String(), Identifier, Underscore)
+		}
+		p.advance()
+		if _, err := p.expect(Eq); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpression(lowest)
+		if err != nil {
+			return nil, err
+		}
+		where.Names = append(where.Names, name.Value)
+		where.Values = append(where.Values, value)
+		if p.tok.Type != Comma {
+			break
+		}
+		p.advance()
+	}
+	where.nodeBase = p.spanFrom(start)
+	return where, nil
+}
+
+func (p *Parser) parseBinary(minPrec int) (Expr, error) {
+	start := p.tok.Offset
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		prec, ok := precedences[p.tok.Type]
+		if !ok || prec <= minPrec {
+			return left, nil
+		}
+		op := p.tok
+		p.advance()
+		if rightAssociative[op.Type] {
+			prec--
+		}
+		right, err := p.parseBinary(prec)
+		if err != nil {
+			return nil, err
+		}
+		if op.Type == Pipe {
+			left = p.pipeCall(start, op, left, right)
+			continue
+		}
+		left = &BinaryExpr{nodeBase: p.spanFrom(start), Op: op, Left: left, Right: right}
+	}
+}
+
+// pipeCall rewrites x |> f into the call f x. When f is itself a call the
+// piped value becomes its last argument, so x |> f a is f a x. start is the
+// offset of the first token of x.
+func (p *Parser) pipeCall(start int, op Token, arg, fn Expr) Expr {
+	if call, ok := fn.(*CallExpr); ok {
+		args := append(slices.Clip(call.Args), arg)
+		return &CallExpr{nodeBase: p.spanFrom(start), Token: op, Callee: call.Callee, Args: args}
+	}
+	return &CallExpr{nodeBase: p.spanFrom(start), Token: op, Callee: fn, Args: []Expr{arg}}
+}
+
+// parseUnary parses prefix - and !, which bind tighter than any binary
+// operator but looser than application: -f x negates the call. A minus is
+// only read here where an operand is expected, at the start of an expression
+// or after an operator; after an operand parseExpression takes it as binary,
+// so 3 - -2 subtracts a negated 2.
+func (p *Parser) parseUnary() (Expr, error) {
+	if p.tok.Type != Minus && p.tok.Type != Bang {
+		return p.parseApplication()
+	}
+	op := p.tok
+	p.advance()
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &UnaryExpr{nodeBase: p.spanFromToken(op), Op: op, Operand: operand}, nil
+}
+
+// parseApplication parses a primary followed by any juxtaposed arguments.
+// Application binds tighter than every binary operator.
+func (p *Parser) parseApplication() (Expr, error) {
+	tok := p.tok
+	callee, err := p.parseMember()
+	if err != nil {
+		return nil, err
+	}
+	var args []Expr
+	for startsPrimary(p.tok.Type) {
+		arg, err := p.parseMember()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) == 0 {
+		return callee, nil
+	}
+	return &CallExpr{nodeBase: p.spanFromToken(tok), Token: tok, Callee: callee, Args: args}, nil
+}
+
+// parseMember parses a primary followed by any .name accesses and [index]
+// indexing, which bind tighter than application: f r.x passes r.x to f, and
+// f xs[0] passes xs[0]. So a list literal passed as an argument needs
+// parentheses, as f [1] indexes f.
+func (p *Parser) parseMember() (Expr, error) {
+	start := p.tok.Offset
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.tok.Type {
+		case Dot:
+			dot := p.tok
+			p.advance()
+			name, err := p.parseMemberName()
+			if err != nil {
+				return nil, err
+			}
+			expr = &MemberExpr{nodeBase: p.spanFrom(start), Token: dot, Object: expr, Name: name.Value}
+		case LBracket:
+			lbracket := p.tok
+			p.advance()
+			index, err := p.parseExpression(lowest)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(RBracket); err != nil {
+				return nil, err
+			}
+			expr = &IndexExpr{nodeBase: p.spanFrom(start), Token: lbracket, Object: expr, Index: index}
+		default:
+			return expr, nil
+		}
+	}
+}
+
+// parseMemberName parses a field name after a dot or as a record key.
+// Nothing else can appear there, so any word is accepted as a plain name,
+// keywords, true, false and _ included: r.let, r.true and { if: 1 } are
+// fine.
+func (p *Parser) parseMemberName() (Token, error) {
+	tok := p.tok
+	if tok.Type != Identifier && tok.Type != Underscore && tok.Type != Boolean && !tok.Type.IsKeyword() {
+		return tok, p.unexpected("a field name", Identifier)
+	}
+	p.advance()
+	return tok, nil
+}
+
+func startsPrimary(t TokenType) bool {
+	switch t {
+	case IntNumber, FloatNumber, Str, CharLiteral, InterpStart, Boolean, Identifier, LParen, LBrace, LBracket:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) parsePrimary() (Expr, error) {
+	tok := p.tok
+	switch tok.Type {
+	case IntNumber, FloatNumber:
+		p.advance()
+		return &NumberLiteral{nodeBase: p.spanFromToken(tok), Token: tok}, nil
+	case Str:
+		p.advance()
+		return &StringLiteral{nodeBase: p.spanFromToken(tok), Token: tok}, nil
+	case CharLiteral:
+		p.advance()
+		return &CharacterLiteral{nodeBase: p.spanFromToken(tok), Token: tok}, nil
+	case InterpStart:
+		return p.parseInterpolation()
+	case Boolean:
+		p.advance()
+		return &BoolLiteral{nodeBase: p.spanFromToken(tok), Token: tok}, nil
+	case Identifier:
+		p.advance()
+		return &Ident{nodeBase: p.spanFromToken(tok), Token: tok, Name: tok.Value}, nil
+	case LParen:
+		p.advance()
+		expr, err := p.parseExpression(lowest)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(RParen); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case LBrace:
+		p.advance()
+		if p.tok.Type == RBrace || p.peek().Type == Colon {
+			return p.parseRecord(tok)
+		}
+		return p.parseBlock(tok)
+	case LBracket:
+		return p.parseList()
+	case If:
+		return p.parseIf()
+	case Let:
+		_, letIn, err := p.parseLet(true)
+		return letIn, err
+	default:
+		return nil, p.unexpected("an expression")
+	}
+}
+
+// parseList parses a list literal such as [1, 2, 3]. As in records, a
+// trailing comma is an error.
+func (p *Parser) parseList() (Expr, error) {
+	list := &ListExpr{Token: p.tok}
+	p.advance()
+	if p.tok.Type == RBracket {
+		p.advance()
+		list.nodeBase = p.spanFromToken(list.Token)
+		return list, nil
+	}
+	for {
+		e, err := p.parseExpression(lowest)
+		if err != nil {
+			return nil, err
+		}
+		list.Elements = append(list.Elements, e)
+		switch p.tok.Type {
+		case Comma:
+			p.advance()
+		case RBracket:
+			p.advance()
+			list.nodeBase = p.spanFromToken(list.Token)
+			return list, nil
+		default:
+			return nil, p.unexpected(`"," or "]"`, Comma, RBracket)
+		}
+	}
+}
+
+// parseRecord parses a record literal such as { x: 1, y: 2 } after its
+// opening brace. A brace followed by a name and a colon starts a record
+// rather than a block, as does {}.
+func (p *Parser) parseRecord(lbrace Token) (Expr, error) {
+	record := &RecordLiteral{Token: lbrace}
+	if p.tok.Type == RBrace {
+		p.advance()
+		record.nodeBase = p.spanFromToken(lbrace)
+		return record, nil
+	}
+	for {
+		key, err := p.parseMemberName()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(Colon); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpression(lowest)
+		if err != nil {
+			return nil, err
+		}
+		record.Keys = append(record.Keys, key.Value)
+		record.Values = append(record.Values, value)
+		switch p.tok.Type {
+		case Comma:
+			p.advance()
+		case RBrace:
+			p.advance()
+			record.nodeBase = p.spanFromToken(lbrace)
+			return record, nil
+		default:
+			return nil, p.unexpected(`"," or "}"`, Comma, RBrace)
+		}
+	}
+}
+
+// parseBlock parses the statements of a block after its opening brace,
+// followed by an optional final expression whose value the block takes.
+func (p *Parser) parseBlock(lbrace Token) (Expr, error) {
+	block := &BlockExpr{Token: lbrace}
+	for {
+		start := p.tok.Offset
+		var expr Expr
+		var err error
+		switch p.tok.Type {
+		case RBrace:
+			p.advance()
+			block.nodeBase = p.spanFromToken(lbrace)
+			return block, nil
+		case Semicolon, Newline:
+			p.advance()
+			continue
+		case Let:
+			var st Statement
+			if st, expr, err = p.parseLet(false); err != nil {
+				return nil, err
+			}
+			if expr == nil {
+				block.Statements = append(block.Statements, st)
+				continue
+			}
+		default:
+			if expr, err = p.parseExpression(lowest); err != nil {
+				return nil, err
+			}
+		}
+		if p.tok.Type == RBrace {
+			p.advance()
+			block.nodeBase = p.spanFromToken(lbrace)
+			block.Result = expr
+			return block, nil
+		}
+		if !isTerminator(p.tok.Type) && !isAssignment(p.tok.Type) {
+			return nil, p.unexpected(`";" or "}"`, Semicolon, RBrace)
+		}
+		st, err := p.finishStatement(start, expr)
+		if err != nil {
+			return nil, err
+		}
+		block.Statements = append(block.Statements, st)
+	}
+}
+
+func (p *Parser) parseInterpolation() (Expr, error) {
+	tok := p.tok
+	p.advance()
+	parts := []Expr{&StringLiteral{nodeBase: p.spanFromToken(tok), Token: tok}}
+	for {
+		expr, err := p.parseExpression(lowest)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, expr)
+		text := p.tok
+		switch text.Type {
+		case InterpMiddle:
+			p.advance()
+			parts = append(parts, &StringLiteral{nodeBase: p.spanFromToken(text), Token: text})
+		case InterpEnd:
+			p.advance()
+			parts = append(parts, &StringLiteral{nodeBase: p.spanFromToken(text), Token: text})
+			return &InterpStringExpr{nodeBase: p.spanFromToken(tok), Token: tok, Parts: parts}, nil
+		default:
+			return nil, p.unexpected(`"}"`, InterpMiddle, InterpEnd)
+		}
+	}
+}
+
+// parseIf parses an if expression. The else branch extends as far right as
+// possible, so `if c then 1 else 2 + 3` adds 3 in the else branch only.
+func (p *Parser) parseIf() (Expr, error) {
+	tok := p.tok
+	p.advance()
+	cond, err := p.parseExpression(lowest)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(Then); err != nil {
+		return nil, err
+	}
+	then, err := p.parseExpression(lowest)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(Else); err != nil {
+		return nil, err
+	}
+	alt, err := p.parseExpression(lowest)
+	if err != nil {
+		return nil, err
+	}
+	return &IfExpr{nodeBase: p.spanFromToken(tok), Token: tok, Cond: cond, Then: then, Else: alt}, nil
+}
+
+// expect consumes the current token if it has type t.
+func (p *Parser) expect(t TokenType) (Token, error) {
+	tok := p.tok
+	if tok.Type != t {
+		return tok, p.unexpected(t.String(), t)
+	}
+	p.advance()
+	return tok, nil
+}
+
+// unexpected reports the current token as a syntax error. A lexing error
+// that ended the input takes precedence, since it is the real cause.
+func (p *Parser) unexpected(want string, expected ...TokenType) error {
+	if p.tok.Type == Eof && p.err != nil {
+		return p.err
+	}
+	found := "end of input"
+	line, column := p.endLine, p.endColumn
+	if p.tok.Type != Eof {
+		found = fmt.Sprintf("%q", p.tok.Value)
+		line, column = p.tok.Line, p.tok.Column
+	}
+	return &ParseError{
+		Line:     line,
+		Column:   column,
+		Message:  fmt.Sprintf("expected %s, found %s", want, found),
+		Expected: expected,
+		Found:    p.tok,
+	}
+}
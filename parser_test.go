@@ -0,0 +1,643 @@
+package ged
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// parseExpr parses src as a single expression, failing the test if it
+// does not parse.
+func parseExpr(t *testing.T, src string) Expr {
+	t.Helper()
+	expr, err := NewParser(NewLexer(src)).ParseExpression()
+	if err != nil {
+		t.Fatalf("%q: %v", src, err)
+	}
+	return expr
+}
+
+// parseProgram parses src, failing the test if it does not parse.
+func parseProgram(t *testing.T, src string) *Program {
+	t.Helper()
+	program, err := NewParser(NewLexer(src)).ParseProgram()
+	if err != nil {
+		t.Fatalf("%q: %v", src, err)
+	}
+	return program
+}
+
+func TestParseExpression(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"1 + 2 * 3", "(1 + (2 * 3))"},
+		{"1 - 2 - 3", "((1 - 2) - 3)"},
+		{"(1 + 2) * 3", "((1 + 2) * 3)"},
+		{"a / b * c % d", "(((a / b) * c) % d)"},
+		{"x", "x"},
+		{"((2.5))", "2.5"},
+		{"1 * (2 + 3) - 4", "((1 * (2 + 3)) - 4)"},
+		{"f a b", "(f a b)"},
+		{"f a + b", "((f a) + b)"},
+		{"f (a + b) c", "(f (a + b) c)"},
+		{`printf "Hi, %s!" a`, `(printf "Hi, %s!" a)`},
+		{`sayHello "world"`, `(sayHello "world")`},
+		{`f 'a' '\n' '\''`, `(f 'a' '\n' '\'')`},
+		{"f a * g b - 1", "(((f a) * (g b)) - 1)"},
+		{"(f a) b", "((f a) b)"},
+		{"println + 420 69", "(println + (420 69))"},
+		{"-x", "(-x)"},
+		{"!flag", "(!flag)"},
+		{"-a * b", "((-a) * b)"},
+		{"1 - -2", "(1 - (-2))"},
+		{"--x", "(-(-x))"},
+		{"!!flag", "(!(!flag))"},
+		{"-f x", "(-(f x))"},
+		{"f - x", "(f - x)"},
+		{`if x == 0 then "zero" else "nonzero"`, `(if (x == 0) then "zero" else "nonzero")`},
+		{"if a then if b then 1 else 2 else 3", "(if a then (if b then 1 else 2) else 3)"},
+		{"if a then 1 else if b then 2 else 3", "(if a then 1 else (if b then 2 else 3))"},
+		{"1 + (if true then 2 else 3) * 4", "(1 + ((if true then 2 else 3) * 4))"},
+		{"if c then 1 else 2 + 3", "(if c then 1 else (2 + 3))"},
+		{"a + 1 < b * 2", "((a + 1) < (b * 2))"},
+		{"f (if c then 1 else 2)", "(f (if c then 1 else 2))"},
+		{`"Hi, ${name}!"`, `"Hi, ${name}!"`},
+		{`"${a + b}"`, `"${(a + b)}"`},
+		{`f "${g x}" y`, `(f "${(g x)}" y)`},
+		{`"$5 \${x}"`, `"$5 \${x}"`},
+		{"a || b && c == d", "(a || (b && (c == d)))"},
+		{"a && b || c", "((a && b) || c)"},
+		{"!a && b", "((!a) && b)"},
+		{"record.let", "(record.let)"},
+		{"{ if: 1 }", "{if: 1}"},
+		{"{}", "{}"},
+		{"{ a: 1 + 2, b: f x }", "{a: (1 + 2), b: (f x)}"},
+		{"f r.x s.y.z", "(f (r.x) ((s.y).z))"},
+		{"(f r).else + 1", "(((f r).else) + 1)"},
+		{"{a: {b: 1}}.a.b", "(({a: {b: 1}}.a).b)"},
+		{"f {x: 1}", "(f {x: 1})"},
+		{"{ 1 }", "{1}"},
+		{"{ let a = 1; a + 1 }", "{let a = 1; (a + 1)}"},
+		{"{ f x; }", "{(f x);}"},
+		{"{ ;; x = 2; x }", "{x = 2; x}"},
+		{"{ a: 1 }", "{a: 1}"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"2 * 3 ** 2", "(2 * (3 ** 2))"},
+		{"2 ** 3 * 2", "((2 ** 3) * 2)"},
+		{"(2 ** 3) ** 2", "((2 ** 3) ** 2)"},
+		{"-2 ** 2", "((-2) ** 2)"},
+		{"f x ** 2", "((f x) ** 2)"},
+		{"[]", "[]"},
+		{"[1, 2 + 3, f x]", "[1, (2 + 3), (f x)]"},
+		{"a[0][1]", "((a[0])[1])"},
+		{"a[i + 1]", "(a[(i + 1)])"},
+		{"f xs[0] y", "(f (xs[0]) y)"},
+		{"f [1]", "(f[1])"},
+		{"f ([1])", "(f [1])"},
+		{"r.xs[0].y", "(((r.xs)[0]).y)"},
+		{"[[1], []][0]", "([[1], []][0])"},
+		{"-xs[0]", "(-(xs[0]))"},
+		{"let x = 5 in x + 1", "(let x = 5 in (x + 1))"},
+		{"let x = 1 in let y = x + 1 in x * y", "(let x = 1 in (let y = (x + 1) in (x * y)))"},
+		{"(let x = 1 in x) + 1", "((let x = 1 in x) + 1)"},
+		{"f (let x = 1 in x)", "(f (let x = 1 in x))"},
+		{"if a then let x = 1 in x else 2", "(if a then (let x = 1 in x) else 2)"},
+		{"let x = if a then 1 else 2 in x", "(let x = (if a then 1 else 2) in x)"},
+		{"5 |> double", "(double 5)"},
+		{"x |> f |> g", "(g (f x))"},
+		{"x |> add 1", "(add 1 x)"},
+		{"a + 1 |> f || b", "((f || b) (a + 1))"},
+		{"x |> (f |> g)", "(g f x)"},
+		{"x * y where x = 2, y = x + 1", "((x * y) where x = 2, y = (x + 1))"},
+		{"if c then a else b where b = 1", "(if c then a else (b where b = 1))"},
+		{"(if c then a else b) where a = 1, b = (c where c = 2)", "((if c then a else b) where a = 1, b = (c where c = 2))"},
+		{"{p: (a where a = 1), q: a where a = 2}", "{p: (a where a = 1), q: (a where a = 2)}"},
+		{"r.true + r.false + r._", "(((r.true) + (r.false)) + (r._))"},
+		{"{true: 1, _: 2, where: 3}", "{true: 1, _: 2, where: 3}"},
+	} {
+		if got := parseExpr(t, tt.src).String(); got != tt.want {
+			t.Errorf("%q: parsed as %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseExpressionTree(t *testing.T) {
+	sum, ok := parseExpr(t, "1 + x * 3").(*BinaryExpr)
+	if !ok || sum.Op.Type != Plus {
+		t.Fatalf("got %#v, want a sum", sum)
+	}
+	if n, ok := sum.Left.(*NumberLiteral); !ok || n.Token.Value != "1" {
+		t.Errorf("left operand is %#v, want the number 1", sum.Left)
+	}
+	product, ok := sum.Right.(*BinaryExpr)
+	if !ok || product.Op.Type != Asterisk {
+		t.Fatalf("right operand is %#v, want a product", sum.Right)
+	}
+	if x, ok := product.Left.(*Ident); !ok || x.Name != "x" {
+		t.Errorf("product's left operand is %#v, want x", product.Left)
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"(1 + 2", "parse error at line 1, col 7: expected rparen, found end of input"},
+		{"1 +\n  * 2", `parse error at line 2, col 3: expected an expression, found "*"`},
+		{"1 )", `parse error at line 1, col 3: expected end of input, found ")"`},
+		{"1 + @", "lex error at line 1, col 5: unknown token '@'"},
+		{"1 @", "lex error at line 1, col 3: unknown token '@'"},
+		{"if x else 2", `parse error at line 1, col 6: expected then, found "else"`},
+		{"if x then 1", "parse error at line 1, col 12: expected else, found end of input"},
+		{`"${a b c`, "lex error at line 1, col 2: unterminated interpolation"},
+		{`"${}"`, `parse error at line 1, col 4: expected an expression, found ""`},
+		{"r.", "parse error at line 1, col 3: expected a field name, found end of input"},
+		{"r.1", `parse error at line 1, col 3: expected a field name, found "1"`},
+		{"{a: 1; b: 2}", `parse error at line 1, col 6: expected "," or "}", found ";"`},
+		{"{a: 1,}", `parse error at line 1, col 7: expected a field name, found "}"`},
+		{"{1: 2}", `parse error at line 1, col 2: expected a field name, found "1"`},
+		{"{ 1 ) }", `parse error at line 1, col 5: expected ";" or "}", found ")"`},
+		{"{ 1 ", "parse error at line 1, col 4: expected \";\" or \"}\", found end of input"},
+		{"{ let a = 1 }", `parse error at line 1, col 13: expected semicolon, found "}"`},
+		{"{ 1; 2", "parse error at line 1, col 7: expected \";\" or \"}\", found end of input"},
+		{"[1, ]", `parse error at line 1, col 5: expected an expression, found "]"`},
+		{"[", "parse error at line 1, col 2: expected an expression, found end of input"},
+		{"a[]", `parse error at line 1, col 3: expected an expression, found "]"`},
+		{"a[1", "parse error at line 1, col 4: expected rbracket, found end of input"},
+		{"[,]", `parse error at line 1, col 2: expected an expression, found ","`},
+		{"let x = 1", "parse error at line 1, col 10: expected in, found end of input"},
+		{"(let f x = x in f 1)", `parse error at line 1, col 8: expected eq, found "x"`},
+		{"let x = 1 in", "parse error at line 1, col 13: expected an expression, found end of input"},
+		{"(let x = 1; x)", `parse error at line 1, col 11: expected in, found ";"`},
+	} {
+		_, err := NewParser(NewLexer(tt.src)).ParseExpression()
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParseStatement(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"let x = 1 + 2;", "let x = (1 + 2);"},
+		{"let y = (a);", "let y = a;"},
+		{"let inc a = a + 1;", "let inc a = (a + 1);"},
+		{"let add a b = a + b*2;", "let add a b = (a + (b * 2));"},
+		{`let sayHello a b = printf "Hi, %s!" a;`, `let sayHello a b = (printf "Hi, %s!" a);`},
+		{"let _ = f 1;", "let _ = (f 1);"},
+		{"let k a _ = a;", "let k a _ = a;"},
+		{"x += 1;", "x = (x + 1);"},
+		{"x -= 1;", "x = (x - 1);"},
+		{"x *= a + b;", "x = (x * (a + b));"},
+		{"x /= 2;", "x = (x / 2);"},
+	} {
+		st, err := NewParser(NewLexer(tt.src)).ParseStatement()
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got := st.String(); got != tt.want {
+			t.Errorf("%q: parsed as %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseFunctionDef(t *testing.T) {
+	for _, tt := range []struct {
+		src    string
+		params []string
+	}{
+		{"let x = 1;", nil},
+		{"let inc a = a;", []string{"a"}},
+		{"let add a b = a;", []string{"a", "b"}},
+	} {
+		st, err := NewParser(NewLexer(tt.src)).ParseStatement()
+		if err != nil {
+			t.Fatalf("%q: %v", tt.src, err)
+		}
+		fn, ok := st.(*FunctionDef)
+		if tt.params == nil {
+			if _, ok := st.(*LetStatement); !ok {
+				t.Errorf("%q: got %T, want a *LetStatement", tt.src, st)
+			}
+			continue
+		}
+		if !ok || !slices.Equal(fn.Params, tt.params) {
+			t.Errorf("%q: got %#v, want a function of %v", tt.src, st, tt.params)
+			continue
+		}
+		if _, ok := fn.Body.(*Ident); !ok {
+			t.Errorf("%q: body is %#v, want an identifier", tt.src, fn.Body)
+		}
+	}
+}
+
+func TestParseStatementErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"let = 1;", `parse error at line 1, col 5: expected identifier, found "="`},
+		{"let x 1;", `parse error at line 1, col 7: expected eq, found "1"`},
+		{"let x = 1", "parse error at line 1, col 10: expected semicolon, found end of input"},
+		{"let x = 1 let", `parse error at line 1, col 11: expected semicolon, found "let"`},
+		{"1 = 2;", `parse error at line 1, col 3: expected semicolon, found "="`},
+		{"let f a 1 = a;", `parse error at line 1, col 9: expected eq, found "1"`},
+		{"let if = 1;", `parse error at line 1, col 5: expected identifier, found "if"`},
+		{"println _;", `parse error at line 1, col 9: expected semicolon, found "_"`},
+		{"_ = 1;", `parse error at line 1, col 1: expected an expression, found "_"`},
+		{"let x = _;", `parse error at line 1, col 9: expected an expression, found "_"`},
+		{"x where;", `parse error at line 1, col 8: expected identifier, found ";"`},
+		{"x where x;", `parse error at line 1, col 10: expected eq, found ";"`},
+		{"x where x = 1,;", `parse error at line 1, col 15: expected identifier, found ";"`},
+		{"[x where x = 1, 2];", `parse error at line 1, col 17: expected identifier, found "2"`},
+		{"f x += 1;", `parse error at line 1, col 5: expected semicolon, found "+="`},
+		{"1 += 1;", `parse error at line 1, col 3: expected semicolon, found "+="`},
+		{"let x = .;", `parse error at line 1, col 9: expected an expression, found "."`},
+	} {
+		_, err := NewParser(NewLexer(tt.src)).ParseStatement()
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParseProgram(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []string
+	}{
+		{sample, []string{"(println + (420 69));", `let sayHello a b = (printf "Hi, %s!" a);`, `(sayHello "world");`}},
+		{";; x;;;", []string{"x;"}},
+		{"", nil},
+		{"f x; let y = 2;", []string{"(f x);", "let y = 2;"}},
+		{"let x = 1; x = 1 + 2;", []string{"let x = 1;", "x = (1 + 2);"}},
+		{"let let_ = 1; let r = {let: 1, then: r.if};", []string{"let let_ = 1;", "let r = {let: 1, then: (r.if)};"}},
+	} {
+		program, err := NewParser(NewLexer(tt.src)).ParseProgram()
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		var got []string
+		for _, st := range program.Statements {
+			got = append(got, st.String())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: parsed as %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseProgramErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"x; @", "lex error at line 1, col 4: unknown token '@'"},
+		{"x; y", "parse error at line 1, col 5: expected semicolon, found end of input"},
+		{"f x = 1;", `parse error at line 1, col 5: expected semicolon, found "="`},
+		{"x = 1", "parse error at line 1, col 6: expected semicolon, found end of input"},
+		{"let f x = 1 in f;", `parse error at line 1, col 13: expected semicolon, found "in"`},
+		{"\n\t\t\tprintln (420 +);", `parse error at line 2, col 18: expected an expression, found ")"`},
+	} {
+		_, err := NewParser(NewLexer(tt.src)).ParseProgram()
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParseProgramRecovers(t *testing.T) {
+	for _, tt := range []struct {
+		src    string
+		want   []string
+		errors []string
+	}{
+		{
+			"let a = 1;\nlet = 2;\nlet b = 3;\nlet c 4;\nlet d = a;",
+			[]string{"let a = 1;", "let b = 3;", "let d = a;"},
+			[]string{
+				`parse error at line 2, col 5: expected identifier, found "="`,
+				`parse error at line 4, col 7: expected eq, found "4"`,
+			},
+		},
+		{
+			"let = 1; x @",
+			nil,
+			[]string{
+				`parse error at line 1, col 5: expected identifier, found "="`,
+				"lex error at line 1, col 12: unknown token '@'",
+			},
+		},
+		{"x @", nil, []string{"lex error at line 1, col 3: unknown token '@'"}},
+	} {
+		program, err := NewParser(NewLexer(tt.src)).ParseProgram()
+		var errs ParseErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%q: got error %v, want ParseErrors", tt.src, err)
+			continue
+		}
+		var got, gotErrs []string
+		for _, st := range program.Statements {
+			got = append(got, st.String())
+		}
+		for _, err := range errs {
+			gotErrs = append(gotErrs, err.Error())
+		}
+		if !slices.Equal(got, tt.want) || !slices.Equal(gotErrs, tt.errors) {
+			t.Errorf("%q: parsed %q with errors %q, want %q with %q", tt.src, got, gotErrs, tt.want, tt.errors)
+		}
+	}
+}
+
+func TestParseErrorsMatchSentinels(t *testing.T) {
+	_, err := NewParser(NewLexer("let = 1; x @")).ParseProgram()
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 1 || parseErr.Column != 5 {
+		t.Errorf("got error %v, want a *ParseError at 1:5", err)
+	}
+	if !errors.Is(err, UnknownTokenError) {
+		t.Errorf("got error %v, want it to wrap UnknownTokenError", err)
+	}
+}
+
+// TestUnaryOrBinaryMinus checks that a minus after an operand is binary,
+// however it is spaced, and one where an operand is expected is unary.
+func TestUnaryOrBinaryMinus(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"-x", "(-x)"},
+		{"a - b", "(a - b)"},
+		{"a -b", "(a - b)"},
+		{"a-b", "(a - b)"},
+		{"f -1", "(f - 1)"},
+		{"f (-1)", "(f (-1))"},
+		{"- - x", "(-(-x))"},
+		{"-5 + 3", "((-5) + 3)"},
+		{"3 - -2", "(3 - (-2))"},
+	} {
+		if got := parseExpr(t, tt.src).String(); got != tt.want {
+			t.Errorf("%q: parsed as %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseMultilineStatement(t *testing.T) {
+	program := parseProgram(t, "let total =\n  1 +\n  2 * 3;\nprintln\n  total;")
+	if got, want := program.String(), "let total = (1 + (2 * 3));\n(println total);"; got != want {
+		t.Fatalf("parsed as %s, want %s", got, want)
+	}
+	let := program.Statements[0].(*LetStatement)
+	sum := let.Value.(*BinaryExpr)
+	product := sum.Right.(*BinaryExpr)
+	for _, tt := range []struct {
+		what string
+		tok  Token
+		want string
+	}{
+		{"let", let.Token, "1:1"},
+		{"+", sum.Op, "2:5"},
+		{"2", product.Left.(*NumberLiteral).Token, "3:3"},
+		{"*", product.Op, "3:5"},
+	} {
+		if got := fmt.Sprintf("%d:%d", tt.tok.Line, tt.tok.Column); got != tt.want {
+			t.Errorf("%s is at %s, want %s", tt.what, got, tt.want)
+		}
+	}
+}
+
+// TestMissingSemicolonPosition checks that a statement left open at the end
+// of input is reported just past its last token rather than at the end of
+// any trailing whitespace and comments.
+func TestMissingSemicolonPosition(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"let a = 1;\nlet b =\n  a +\n  2\n", "parse error at line 4, col 4: expected semicolon, found end of input"},
+		{"x\n\n\n", "parse error at line 1, col 2: expected semicolon, found end of input"},
+		{"let a = 1;\nf a  // no semicolon\n   ", "parse error at line 2, col 4: expected semicolon, found end of input"},
+		{"let x = (1 +\n  2 /* open */", "parse error at line 2, col 4: expected rparen, found end of input"},
+		{"let x =\n\n", "parse error at line 1, col 8: expected an expression, found end of input"},
+	} {
+		_, err := NewParser(NewLexer(tt.src)).ParseProgram()
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParserCollectsComments(t *testing.T) {
+	l := NewLexer("// head\nlet x = 1; /* mid */ x // tail\n+ 2;")
+	l.KeepComments = true
+	p := NewParser(l)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := program.String(), "let x = 1;\n(x + 2);"; got != want {
+		t.Errorf("parsed as %s, want %s", got, want)
+	}
+	var got []string
+	for _, tok := range p.Comments {
+		got = append(got, tok.Value)
+	}
+	if want := []string{"// head", "/* mid */", "// tail"}; !slices.Equal(got, want) {
+		t.Errorf("collected comments %q, want %q", got, want)
+	}
+}
+
+// TestParserKindsAgree drives the same programs through both kinds of
+// parser, which must build the same trees and fail with the same errors
+// as NewParser.
+func TestParserKindsAgree(t *testing.T) {
+	for _, src := range []string{
+		"1 + 2 * 3 - 4 / 5 % 6;",
+		"2 ** 3 ** 2 * -x;",
+		"a || b && c || d && e;",
+		"a < b == c >= d != e <= f > g;",
+		"- -x - -1 * -(y);",
+		"let f x y = x + y; f 1 2 + f 3 4;",
+		"let x = if a then b else c + 1; x = x - 1;",
+		"{ let y = 2; y * 3 } + 1;",
+		"let z = let a = 1 in a + 2; z;",
+		"[1, 2 + 3][0] ** 2 ** -1;",
+		"r.x + {a: 1 * 2, b: -3}.b; // note",
+		"\"a ${x + 1} b\" + s;",
+		"!a == !b != c && !(d || e);",
+		"-f x ** 2 / 3 * g (1 + 2);",
+		"5 |> double |> add 1 || f;",
+		"x |> (f |> g) |> h 2;",
+		"let w = (if c then a else b where b = 1) + x where x = 2, y = x;",
+		"let z = [(a where a = 1), 3]; f (a where a = 1);",
+		"1 + ;",
+		"let = 2;",
+		"(1 + 2;",
+		"a || ;",
+		"2 ** ;",
+		"1 < < 2;",
+		"x |> ;",
+		"x where x = 1,;",
+	} {
+		want, wantErr := NewParser(NewLexer(src)).ParseProgram()
+		tokens, err := NewLexer(src).Tokenize()
+		if err != nil {
+			t.Fatalf("%q: %v", src, err)
+		}
+		for _, kind := range []ParserKind{PrecedenceClimbing, RecursiveDescent} {
+			got, err := NewProgramParser(kind).Parse(tokens)
+			if fmt.Sprint(err) != fmt.Sprint(wantErr) {
+				t.Errorf("%q: kind %d failed with %v, want %v", src, kind, err, wantErr)
+				continue
+			}
+			if err == nil && got.String() != want.String() {
+				t.Errorf("%q: kind %d parsed as %s, want %s", src, kind, got, want)
+			}
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	src := "let r = f  (a + b) ([1, 2])  * 3;\n  g x |> h;\nlet s = \"a${x}b\" == if c then {1} else -y.z[0];"
+	tokens, err := NewLexer(src).Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []ParserKind{PrecedenceClimbing, RecursiveDescent} {
+		program, err := NewProgramParser(kind).Parse(tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		let := program.Statements[0].(*LetStatement)
+		product := let.Value.(*BinaryExpr)
+		call := product.Left.(*CallExpr)
+		pipe := program.Statements[1].(*ExpressionStatement)
+		equal := program.Statements[2].(*LetStatement).Value.(*BinaryExpr)
+		cond := equal.Right.(*IfExpr)
+		neg := cond.Else.(*UnaryExpr)
+		for _, tt := range []struct {
+			node interface{ Source(string) string }
+			want string
+		}{
+			{let, "let r = f  (a + b) ([1, 2])  * 3;"},
+			{product, "f  (a + b) ([1, 2])  * 3"},
+			{call, "f  (a + b) ([1, 2])"},
+			{call.Args[0], "a + b"},
+			{call.Args[1], "[1, 2]"},
+			{product.Right, "3"},
+			{pipe, "g x |> h;"},
+			{pipe.Expr, "g x |> h"},
+			{equal.Left, `"a${x}b"`},
+			{cond, "if c then {1} else -y.z[0]"},
+			{cond.Then, "{1}"},
+			{neg, "-y.z[0]"},
+			{neg.Operand, "y.z[0]"},
+			{neg.Operand.(*IndexExpr).Object, "y.z"},
+		} {
+			if got := tt.node.Source(src); got != tt.want {
+				t.Errorf("kind %d: %s spans %q, want %q", kind, tt.node, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestParseNewlineMode(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"a + b\nc", "(a + b);\nc;"},
+		{"a +\n  b\n", "(a + b);"},
+		{"f (a\n  + b)\n", "(f (a + b));"},
+		{"let xs = [1,\n 2,\n 3]\nxs", "let xs = [1, 2, 3];\nxs;"},
+		{"x = 1; y = 2\n\n\nz", "x = 1;\ny = 2;\nz;"},
+		{"let f x = if x\n then 1\n else 2\nf 3", "let f x = (if x then 1 else 2);\n(f 3);"},
+		{"let b = {\n  let y = 2\n  y * 3\n}\nb", "let b = {let y = 2; (y * 3)};\nb;"},
+		{"data\n  |> g\n  |> h // c\nk", "(h (g data));\nk;"},
+		{"let r = {a: 1,\n b: 2\n}\r\nr.a\r\n", "let r = {a: 1, b: 2};\n(r.a);"},
+		{"let z = let a = 1\n in a\nz", "let z = (let a = 1 in a);\nz;"},
+		{"s = \"${a\n}\"\nt", "s = \"${a}\";\nt;"},
+		{"x + y\n  where x = 1,\n    y = 2\nprintln x", "((x + y) where x = 1, y = 2);\n(println x);"},
+	} {
+		l := NewLexer(tt.src)
+		l.Mode = ModeNewline
+		program, err := NewParser(l).ParseProgram()
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got := program.String(); got != tt.want {
+			t.Errorf("%q: parsed as\n%s\nwant\n%s", tt.src, got, tt.want)
+		}
+	}
+	if _, err := NewParser(NewLexer("a + b\nc")).ParseProgram(); err == nil {
+		t.Error("a statement ended at a line break without ModeNewline")
+	}
+}
+
+func TestNewlineModeSpan(t *testing.T) {
+	src := "x = 1 +\n 2\n"
+	l := NewLexer(src)
+	l.Mode = ModeNewline
+	program, err := NewParser(l).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := program.Statements[0].Source(src), "x = 1 +\n 2"; got != want {
+		t.Errorf("statement spans %q, want %q", got, want)
+	}
+}
+
+func TestExpressionStatements(t *testing.T) {
+	program := parseProgram(t, "let f x = x; f x; 1 + 2;")
+	if len(program.Statements) != 3 {
+		t.Fatalf("got %d statements, want 3", len(program.Statements))
+	}
+	for i, want := range []string{"(f x)", "(1 + 2)"} {
+		st, ok := program.Statements[i+1].(*ExpressionStatement)
+		if !ok {
+			t.Errorf("statement %d is a %T, want an expression statement", i+1, program.Statements[i+1])
+			continue
+		}
+		if got := st.Expr.String(); got != want {
+			t.Errorf("statement %d is %s, want %s", i+1, got, want)
+		}
+	}
+}
+
+func TestSpanIncludesLeadingParentheses(t *testing.T) {
+	src := "(f  a b) + c * 2; (g x) |> h; (r).x[0]; (2) ** 3;"
+	for _, kind := range []ParserKind{PrecedenceClimbing, RecursiveDescent} {
+		tokens, err := NewLexer(src).Tokenize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		program, err := NewProgramParser(kind).Parse(tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exprs := make([]Expr, len(program.Statements))
+		for i, st := range program.Statements {
+			exprs[i] = st.(*ExpressionStatement).Expr
+		}
+		member := exprs[2].(*IndexExpr)
+		for _, tt := range []struct {
+			node Node
+			want string
+		}{
+			{program.Statements[0], "(f  a b) + c * 2;"},
+			{exprs[0], "(f  a b) + c * 2"},
+			{exprs[0].(*BinaryExpr).Left, "f  a b"},
+			{exprs[1], "(g x) |> h"},
+			{member, "(r).x[0]"},
+			{member.Object, "(r).x"},
+			{exprs[3], "(2) ** 3"},
+		} {
+			if got := tt.node.Source(src); got != tt.want {
+				t.Errorf("kind %d: %s spans %q, want %q", kind, tt.node, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ged
+
+import "strings"
+
+// Render returns the error message followed by the line of source it points
+// at, with a caret under the column. The other positioned errors render the
+// same way.
+func (e *LexError) Render(source string) string {
+	return render(e.Error(), source, e.Line, e.Column)
+}
+
+func (e *ParseError) Render(source string) string {
+	return render(e.Error(), source, e.Line, e.Column)
+}
+
+func (e *RuntimeError) Render(source string) string {
+	return render(e.Error(), source, e.Line, e.Column)
+}
+
+func (e *CompileError) Render(source string) string {
+	return render(e.Error(), source, e.Line, e.Column)
+}
+
+// render returns message followed by the given line of source and a caret
+// under column. Columns count runes, and tabs before the caret are copied so
+// it lines up however wide the terminal draws them.
+func render(message, source string, line, column int) string {
+	text := sourceLine(source, line)
+	var caret strings.Builder
+	i := 1
+	for _, r := range text {
+		if i >= column {
+			break
+		}
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+		i++
+	}
+	for ; i < column; i++ {
+		caret.WriteRune(' ')
+	}
+	caret.WriteRune('^')
+	return message + "\n" + text + "\n" + caret.String()
+}
+
+// sourceLine returns the one-based line of source, splitting lines the way
+// the lexer counts them, or "" past the end.
+func sourceLine(source string, line int) string {
+	for n := 1; ; n++ {
+		end := strings.IndexAny(source, "\r\n")
+		if n == line {
+			if end < 0 {
+				return source
+			}
+			return source[:end]
+		}
+		if end < 0 {
+			return ""
+		}
+		if strings.HasPrefix(source[end:], "\r\n") {
+			end++
+		}
+		source = source[end+1:]
+	}
+}
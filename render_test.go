@@ -0,0 +1,49 @@
+package ged
+
+import (
+	"errors"
+	"testing"
+)
+
+// renderer is implemented by every positioned error.
+type renderer interface {
+	error
+	Render(source string) string
+}
+
+func TestRender(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"let x = 1;\nlet héé = @;\n", "lex error at line 2, col 11: unknown token '@'\nlet héé = @;\n          ^"},
+		{"let x = 1;\r\n\tlet y = (1 + ;", "parse error at line 2, col 15: expected an expression, found \";\"\n\tlet y = (1 + ;\n\t             ^"},
+		{"let x = 1", "parse error at line 1, col 10: expected semicolon, found end of input\nlet x = 1\n         ^"},
+		{"let a = 1;\rlet b = 2;\r\n1 / 0;", "runtime error at line 3, col 3: division by zero\n1 / 0;\n  ^"},
+	} {
+		err := RunWithOutput(tt.src, nil)
+		var r renderer
+		if !errors.As(err, &r) {
+			t.Errorf("%q: got error %v, want one that renders", tt.src, err)
+			continue
+		}
+		if got := r.Render(tt.src); got != tt.want {
+			t.Errorf("%q: rendered\n%s\nwant\n%s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestRenderPastEnd(t *testing.T) {
+	err := &CompileError{Line: 3, Column: 2, Message: "m"}
+	if got, want := err.Render("a\nb"), "compile error at line 3, col 2: m\n\n ^"; got != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+}
+
+func TestSourceLine(t *testing.T) {
+	src := "a\rb\r\nc\nd"
+	for i, want := range []string{"a", "b", "c", "d", ""} {
+		if got := sourceLine(src, i+1); got != want {
+			t.Errorf("line %d is %q, want %q", i+1, got, want)
+		}
+	}
+}
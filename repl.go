@@ -0,0 +1,73 @@
+package ged
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	prompt             = ">> "
+	continuationPrompt = ".. "
+)
+
+// StartREPL reads statements from in, evaluates them and prints each
+// result to out until in is exhausted. Bindings persist between lines.
+// Input that stops partway through a statement, including one missing its
+// closing semicolon, is continued on the next line.
+func StartREPL(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	interp := NewInterpreter()
+	interp.Out = out
+	var source strings.Builder
+	for {
+		if source.Len() == 0 {
+			fmt.Fprint(out, prompt)
+		} else {
+			fmt.Fprint(out, continuationPrompt)
+		}
+		if !scanner.Scan() {
+			return
+		}
+		line := scanner.Text()
+		if source.Len() == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+		source.WriteString(line)
+		source.WriteString("\n")
+
+		program, err := NewParser(NewLexer(source.String())).ParseProgram()
+		if incomplete(err) {
+			continue
+		}
+		source.Reset()
+		if err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		v, err := interp.Run(program)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		if v != nil {
+			fmt.Fprintln(out, v)
+		}
+	}
+}
+
+// incomplete reports whether err only says that the input ended too soon,
+// meaning more lines could complete it.
+func incomplete(err error) bool {
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		return false
+	}
+	if errors.Is(errs[0], ErrUnterminatedString) || errors.Is(errs[0], ErrUnterminatedComment) {
+		return true
+	}
+	var parseErr *ParseError
+	return errors.As(errs[0], &parseErr) && parseErr.Found.Type == Eof
+}
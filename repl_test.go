@@ -0,0 +1,27 @@
+package ged
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	for _, tt := range []struct {
+		name, input, want string
+	}{
+		{"bindings persist", "let x = 2;\nx * 3;\n", ">> >> 6\n>> "},
+		{"blank lines", "\n  \n1;\n", ">> >> >> 1\n>> "},
+		{"unterminated string", "let s = \"multi\nline\";\nprintln s;\n", ">> .. >> multi\nline\n>> "},
+		{"missing semicolon", "1 +\n2;\n", ">> .. 3\n>> "},
+		{"runtime error", "y;\n1;\n", ">> runtime error at line 1, col 1: undefined variable y\n>> 1\n>> "},
+		{"parse error", "let = ;\n", ">> parse error at line 1, col 5: expected identifier, found \"=\"\n>> "},
+		{"expression statements", "1 + 2;\nlet x = 4;\nprintln x;\n", ">> 3\n>> >> 4\n>> "},
+	} {
+		var out bytes.Buffer
+		StartREPL(strings.NewReader(tt.input), &out)
+		if out.String() != tt.want {
+			t.Errorf("%s: printed %q, want %q", tt.name, &out, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package ged
+
+import "fmt"
+
+// Resolve reports every reference to a name that is never bound, without
+// running program. The built-ins are always in scope, and function
+// parameters are in scope within the function's body.
+//
+// Top-level code may only use names bound above it, as the evaluator runs
+// statements in order. A function body may also refer to names bound
+// further down, since it only looks them up once called, which is what
+// makes recursion and mutually recursive functions work:
+//
+//	let even n = if n == 0 then true else odd (n - 1);
+//	let odd n = if n == 0 then false else even (n - 1);
+//
+// Any predeclared names are in scope everywhere too, like the built-ins.
+func Resolve(program *Program, predeclared ...string) []error {
+	r := &resolver{all: make(map[string]bool)}
+	top := make(map[string]bool)
+	for _, b := range builtins(nil) {
+		top[b.Name] = true
+		r.all[b.Name] = true
+	}
+	for _, name := range predeclared {
+		top[name] = true
+		r.all[name] = true
+	}
+	for _, st := range program.Statements {
+		switch s := st.(type) {
+		case *LetStatement:
+			r.all[s.Name] = true
+		case *FunctionDef:
+			r.all[s.Name] = true
+		}
+	}
+	r.scopes = []map[string]bool{top}
+	for _, st := range program.Statements {
+		r.statement(st)
+	}
+	return r.errs
+}
+
+type resolver struct {
+	// all holds every name bound at the top level of the program
+	all map[string]bool
+	// scopes holds the names visible so far, innermost last
+	scopes []map[string]bool
+	// functions counts the function bodies being resolved
+	functions int
+	errs      []error
+}
+
+// statement resolves st, binding any name it introduces in the innermost
+// scope.
+func (r *resolver) statement(st Statement) {
+	scope := r.scopes[len(r.scopes)-1]
+	switch s := st.(type) {
+	case *LetStatement:
+		r.expr(s.Value)
+		scope[s.Name] = true
+	case *FunctionDef:
+		scope[s.Name] = true
+		params := make(map[string]bool, len(s.Params))
+		for _, param := range s.Params {
+			params[param] = true
+		}
+		r.scopes = append(r.scopes, params)
+		r.functions++
+		r.expr(s.Body)
+		r.functions--
+		r.scopes = r.scopes[:len(r.scopes)-1]
+	case *AssignStatement:
+		// checked first, so the name read back by a compound assignment
+		// reports no second error at the same place
+		if !r.bound(s.Name) {
+			r.errorf(s.Token, "assignment to undefined variable %s", s.Name)
+		}
+		r.expr(s.Value)
+	case *ExpressionStatement:
+		r.expr(s.Expr)
+	}
+}
+
+func (r *resolver) expr(e Expr) {
+	switch n := e.(type) {
+	case *Ident:
+		if !r.bound(n.Name) {
+			r.errorf(n.Token, "undefined variable %s", n.Name)
+		}
+	case *UnaryExpr:
+		r.expr(n.Operand)
+	case *BinaryExpr:
+		r.expr(n.Left)
+		r.expr(n.Right)
+	case *CallExpr:
+		r.expr(n.Callee)
+		for _, arg := range n.Args {
+			r.expr(arg)
+		}
+	case *IfExpr:
+		r.expr(n.Cond)
+		r.expr(n.Then)
+		r.expr(n.Else)
+	case *InterpStringExpr:
+		for _, part := range n.Parts {
+			r.expr(part)
+		}
+	case *MemberExpr:
+		r.expr(n.Object)
+	case *LetInExpr:
+		r.expr(n.Value)
+		r.scopes = append(r.scopes, map[string]bool{n.Name: true})
+		r.expr(n.Body)
+		r.scopes = r.scopes[:len(r.scopes)-1]
+	case *WhereExpr:
+		scope := make(map[string]bool, len(n.Names))
+		r.scopes = append(r.scopes, scope)
+		for i, name := range n.Names {
+			r.expr(n.Values[i])
+			scope[name] = true
+		}
+		r.expr(n.Expr)
+		r.scopes = r.scopes[:len(r.scopes)-1]
+	case *ListExpr:
+		for _, e := range n.Elements {
+			r.expr(e)
+		}
+	case *IndexExpr:
+		r.expr(n.Object)
+		r.expr(n.Index)
+	case *RecordLiteral:
+		for _, v := range n.Values {
+			r.expr(v)
+		}
+	case *BlockExpr:
+		r.scopes = append(r.scopes, make(map[string]bool))
+		for _, st := range n.Statements {
+			r.statement(st)
+		}
+		if n.Result != nil {
+			r.expr(n.Result)
+		}
+		r.scopes = r.scopes[:len(r.scopes)-1]
+	}
+}
+
+func (r *resolver) errorf(tok Token, format string, args ...any) {
+	if n := len(r.errs); n > 0 {
+		if last := r.errs[n-1].(*CompileError); last.Line == tok.Line && last.Column == tok.Column {
+			return
+		}
+	}
+	err := &CompileError{Phase: "resolve", Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)}
+	r.errs = append(r.errs, err)
+}
+
+func (r *resolver) bound(name string) bool {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if r.scopes[i][name] {
+			return true
+		}
+	}
+	return r.functions > 0 && r.all[name]
+}
@@ -0,0 +1,44 @@
+package ged
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want []string
+	}{
+		{runSample, nil},
+		{"println y;", []string{"resolve error at line 1, col 9: undefined variable y"}},
+		{"let x = 1; let f x = x + 1; f x;", nil},
+		{"let f a = a + b;\nf 1;", []string{"resolve error at line 1, col 15: undefined variable b"}},
+		{"let f n = g n; let g n = f n;", nil},
+		{"let fact n = n * fact (n - 1);", nil},
+		{"println x; let x = 1;", []string{"resolve error at line 1, col 9: undefined variable x"}},
+		{"let x = x + 1;", []string{"resolve error at line 1, col 9: undefined variable x"}},
+		{"y = 1; let y = 2; y = 3;", []string{"resolve error at line 1, col 1: assignment to undefined variable y"}},
+		{"let f a = a; a;", []string{"resolve error at line 1, col 14: undefined variable a"}},
+		{`"${q}" + {k: z}.k + r.s;`, []string{
+			"resolve error at line 1, col 4: undefined variable q",
+			"resolve error at line 1, col 14: undefined variable z",
+			"resolve error at line 1, col 21: undefined variable r",
+		}},
+		{"let even n = if n == 0 then true else odd (n - 1);\nlet odd n = if n == 0 then false else even (n - 1);", nil},
+		{"let x = 1; let f y = x + y; let x = f x;", nil},
+		{"let y = { let q = 1; q }; q;", []string{"resolve error at line 1, col 27: undefined variable q"}},
+		{"let x = 1; { let x = 2; x } + x;", nil},
+		{"let xs = [a]; xs[b];", []string{"resolve error at line 1, col 11: undefined variable a", "resolve error at line 1, col 18: undefined variable b"}},
+		{"let v = let w = 1 in w; w;", []string{"resolve error at line 1, col 25: undefined variable w"}},
+		{"let k = let k = 1 in k; k;", nil},
+	} {
+		var got []string
+		for _, err := range Resolve(parseProgram(t, tt.src)) {
+			got = append(got, err.Error())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package ged
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+)
+
+// Severity is how serious a Diagnostic is, named as in SARIF.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNote    Severity = "note"
+)
+
+// Diagnostic is a problem found in a program, in a form meant for tools.
+// Lines and columns are one-based, columns counting runes. EndLine and
+// EndColumn are just past the offending text, or equal to Line and Column
+// when its extent is unknown. A Line of zero means there is no position.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	// Path is the file the problem is in, if known.
+	Path      string `json:"path,omitempty"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+}
+
+// Diagnostics converts the errors returned by the lexer, parser, Resolve,
+// CompileFile or the evaluator into diagnostics, one per problem. Errors
+// that carry no position become diagnostics without one.
+func Diagnostics(err error) []Diagnostic {
+	var diags []Diagnostic
+	collectDiagnostics(err, "", &diags)
+	return diags
+}
+
+func collectDiagnostics(err error, path string, diags *[]Diagnostic) {
+	d := Diagnostic{Severity: SeverityError, Path: path}
+	switch e := err.(type) {
+	case nil:
+		return
+	case *FileError:
+		collectDiagnostics(e.Err, e.Path, diags)
+		return
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			collectDiagnostics(err, path, diags)
+		}
+		return
+	case *LexError:
+		d.Message, d.Line, d.Column = e.Message, e.Line, e.Column
+		d.EndLine, d.EndColumn = spanEnd(e.Line, e.Column, e.Lexeme)
+	case *ParseError:
+		d.Message, d.Line, d.Column = e.Message, e.Line, e.Column
+		d.EndLine, d.EndColumn = e.Line, e.Column
+		// only these tokens are written in the source exactly as their value
+		if t := e.Found.Type; e.Found.Line == e.Line && e.Found.Column == e.Column &&
+			(t == Identifier || t.IsKeyword() || t.IsOperator()) {
+			d.EndLine, d.EndColumn = spanEnd(e.Line, e.Column, e.Found.Value)
+		}
+	case *CompileError:
+		d.Message, d.Line, d.Column = e.Message, e.Line, e.Column
+		d.EndLine, d.EndColumn = e.Line, e.Column
+		if e.Path != "" {
+			d.Path = e.Path
+		}
+	case *RuntimeError:
+		d.Message, d.Line, d.Column = e.Message, e.Line, e.Column
+		d.EndLine, d.EndColumn = e.Line, e.Column
+	default:
+		d.Message = err.Error()
+	}
+	*diags = append(*diags, d)
+}
+
+// spanEnd returns the position just past text, which starts at line and
+// column, counting line breaks the way the lexer does.
+func spanEnd(line, column int, text string) (int, int) {
+	for i, r := range text {
+		switch {
+		case r == '\r' && i+1 < len(text) && text[i+1] == '\n':
+			// counted at the \n
+		case r == '\n' || r == '\r':
+			line, column = line+1, 1
+		default:
+			column++
+		}
+	}
+	return line, column
+}
+
+// ExportSARIF encodes diags as a SARIF 2.1.0 log with a single run, for
+// tools such as code scanning in CI. A diagnostic without a Path refers to
+// the empty URI.
+func ExportSARIF(diags []Diagnostic) ([]byte, error) {
+	results := make([]sarifResult, len(diags))
+	for i, d := range diags {
+		results[i] = sarifResult{Level: string(d.Severity), Message: sarifMessage{Text: d.Message}}
+		if d.Line == 0 && d.Path == "" {
+			continue
+		}
+		loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: fileURI(d.Path)}}
+		if d.Line > 0 {
+			loc.Region = &sarifRegion{
+				StartLine:   d.Line,
+				StartColumn: d.Column,
+				EndLine:     d.EndLine,
+				EndColumn:   d.EndColumn,
+			}
+		}
+		results[i].Locations = []sarifLocation{{PhysicalLocation: loc}}
+	}
+	return json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:       sarifTool{Driver: sarifDriver{Name: "ged"}},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	}, "", "  ")
+}
+
+// fileURI turns path into the URI reference SARIF expects, relative unless
+// path is absolute.
+func fileURI(path string) string {
+	u := url.URL{Path: filepath.ToSlash(path)}
+	if filepath.IsAbs(path) {
+		u.Scheme = "file"
+	}
+	return u.String()
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	Level     string          `json:"level,omitempty"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
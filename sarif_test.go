@@ -0,0 +1,114 @@
+package ged
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	_, parseErr := NewParser(NewLexer("let = 1;")).ParseProgram()
+	for _, tt := range []struct {
+		name string
+		err  error
+		want []Diagnostic
+	}{
+		{"lex", RunWithOutput("let s = \"abc\\q\";", nil), []Diagnostic{
+			{SeverityError, `unknown escape sequence '\q'`, "", 1, 13, 1, 15},
+		}},
+		{"parse", parseErr, []Diagnostic{
+			{SeverityError, `expected identifier, found "="`, "", 1, 5, 1, 6},
+		}},
+		{"resolve", RunWithOutput("let x = 1;\nprintln (x + yy);\nzz;", nil), []Diagnostic{
+			{SeverityError, "undefined variable yy", "", 2, 14, 2, 14},
+			{SeverityError, "undefined variable zz", "", 3, 1, 3, 1},
+		}},
+		{"runtime", RunWithOutput("1 / 0;", nil), []Diagnostic{
+			{SeverityError, "division by zero", "", 1, 3, 1, 3},
+		}},
+		{"other", errors.New("boom"), []Diagnostic{{Severity: SeverityError, Message: "boom"}}},
+		{"nil", nil, nil},
+	} {
+		if got := Diagnostics(tt.err); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiagnosticsFromFile(t *testing.T) {
+	path := writeFile(t, "bad file.ged", "let a = b;\nlet c = d;\n")
+	_, err := CompileFile(path)
+	diags := Diagnostics(err)
+	if len(diags) != 2 || diags[0].Path != path || diags[1].Path != path || diags[1].Line != 2 {
+		t.Fatalf("got %+v, want two diagnostics in %s", diags, path)
+	}
+	out, err := ExportSARIF(diags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := `"uri": "file://` + filepath.ToSlash(filepath.Dir(path)) + `/bad%20file.ged"`
+	if !strings.Contains(string(out), uri) {
+		t.Errorf("got\n%s\nwant it to contain %s", out, uri)
+	}
+}
+
+// TestExportSARIF checks the SARIF log for one lex error and one resolve
+// error.
+func TestExportSARIF(t *testing.T) {
+	diags := Diagnostics(RunWithOutput("let s = \"abc\\q\";", nil))
+	diags = append(diags, Diagnostics(RunWithOutput("let x = 1;\nprintln (x + yy);", nil))...)
+	out, err := ExportSARIF(diags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type region struct{ StartLine, StartColumn, EndLine, EndColumn int }
+	var log struct {
+		Schema  string `json:"$schema"`
+		Version string
+		Runs    []struct {
+			Tool    struct{ Driver struct{ Name string } }
+			Results []struct {
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct{ Region region }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(out, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || !strings.Contains(log.Schema, "sarif") || len(log.Runs) != 1 || log.Runs[0].Tool.Driver.Name != "ged" {
+		t.Fatalf("got\n%s\nwant one ged run in a SARIF 2.1.0 log", out)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for i, want := range []struct {
+		message string
+		region  region
+	}{
+		{`unknown escape sequence '\q'`, region{1, 13, 1, 15}},
+		{"undefined variable yy", region{2, 14, 2, 14}},
+	} {
+		r := results[i]
+		if r.Level != "error" || r.Message.Text != want.message || len(r.Locations) != 1 || r.Locations[0].PhysicalLocation.Region != want.region {
+			t.Errorf("result %d is %+v, want %q at %+v", i, r, want.message, want.region)
+		}
+	}
+}
+
+func TestExportSARIFEmpty(t *testing.T) {
+	out, err := ExportSARIF(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"results": []`) {
+		t.Errorf("got\n%s\nwant an empty results array", out)
+	}
+}
@@ -0,0 +1,729 @@
+package ged
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"go/types"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// goType is the Go type an expression is translated to, or goNone for one
+// that produces no value, such as a call to println.
+type goType string
+
+const (
+	goNone   goType = ""
+	goInt    goType = "int64"
+	goFloat  goType = "float64"
+	goString goType = "string"
+	goBool   goType = "bool"
+)
+
+func (t goType) isNumber() bool {
+	return t == goInt || t == goFloat
+}
+
+func (t goType) String() string {
+	switch t {
+	case goNone:
+		return "no value"
+	case goInt:
+		return "int"
+	case goFloat:
+		return "float"
+	}
+	return string(t)
+}
+
+// goBinding is what a Ged name stands for in the Go output: a variable of
+// type typ named name, or the function fn.
+type goBinding struct {
+	name string
+	typ  goType
+	fn   *FunctionDef
+}
+
+// goInstance is a function translated for one list of argument types.
+// result is only known once its body has been.
+type goInstance struct {
+	name   string
+	result goType
+	known  bool
+}
+
+type instanceKey struct {
+	fn    *FunctionDef
+	types string
+}
+
+// pendingError reports a call to inst made while its body is still being
+// translated, before its result type is known. hint is the type of the
+// nearest enclosing if's other branch, which is taken as a guess at the
+// result when the body is tried again.
+type pendingError struct {
+	inst   *goInstance
+	tok    Token
+	name   string
+	hint   goType
+	hinted bool
+}
+
+func (e *pendingError) Error() string {
+	return fmt.Sprintf("cannot infer the result type of %s", e.name)
+}
+
+// goReserved are the names the Go output declares or imports itself,
+// which a Ged name is never translated to. Go's keywords and predeclared
+// names, such as int, are avoided as well.
+var goReserved = []string{
+	"_", "main", "init", "fmt", "math", "os", "strconv",
+	"fail", "divide", "modulo", "power",
+}
+
+var goHelpers = map[string]string{
+	"fail": `func fail(line, column int, message string) {
+	fmt.Fprintf(os.Stderr, "runtime error at line %d, col %d: %s\n", line, column, message)
+	os.Exit(1)
+}`,
+	"divide": `func divide(l, r float64, line, column int) float64 {
+	if r == 0 {
+		fail(line, column, "division by zero")
+	}
+	return l / r
+}`,
+	"modulo": `func modulo(l, r int64, line, column int) int64 {
+	if r == 0 {
+		fail(line, column, "modulo by zero")
+	}
+	return l % r
+}`,
+	"power": `func power(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}`,
+}
+
+var helperImports = map[string][]string{
+	"fail": {"fmt", "os"},
+}
+
+var helperNeeds = map[string][]string{
+	"divide": {"fail"},
+	"modulo": {"fail"},
+}
+
+type transpiler struct {
+	// used holds every Go name taken so far
+	used map[string]bool
+	// globals holds the top-level bindings, as of the statement being
+	// translated
+	globals map[string]*goBinding
+	// scopes holds the parameters and local bindings visible, innermost
+	// last
+	scopes []map[string]*goBinding
+	// captured holds the globals read from a function body, which must
+	// then keep their type
+	captured  map[string]bool
+	functions int
+	instances map[instanceKey]*goInstance
+	vars      []string
+	main      []string
+	funcs     []string
+	imports   map[string]bool
+	helpers   map[string]bool
+}
+
+// TranspileToGo translates program into the source of a Go main package
+// that runs it, printing the same output and failing with the same runtime
+// errors as the interpreter. So far let bindings, assignments, functions,
+// let and where, if, and operations on numbers, strings and bools are
+// supported, with println and printf as the only built-ins.
+//
+// Every expression must have a single type, known from the program text,
+// as Go's do. A function is translated once for each list of argument
+// types it is called with, and must then always return the same type. A
+// printf format must be a literal string.
+func TranspileToGo(program *Program) (string, error) {
+	t := &transpiler{
+		used:      make(map[string]bool),
+		globals:   make(map[string]*goBinding),
+		captured:  make(map[string]bool),
+		instances: make(map[instanceKey]*goInstance),
+		imports:   make(map[string]bool),
+		helpers:   make(map[string]bool),
+	}
+	for _, name := range goReserved {
+		t.used[name] = true
+	}
+	for _, st := range program.Statements {
+		folded, err := foldStatement(st)
+		if err != nil {
+			return "", err
+		}
+		if err := t.statement(folded); err != nil {
+			var pending *pendingError
+			if errors.As(err, &pending) {
+				return "", compileErrorf(pending.tok, "%s", pending.Error())
+			}
+			return "", err
+		}
+	}
+	src, err := format.Source([]byte(t.file()))
+	if err != nil {
+		return "", fmt.Errorf("formatting Go output: %w", err)
+	}
+	return string(src), nil
+}
+
+func (t *transpiler) file() string {
+	var b strings.Builder
+	b.WriteString("// Code generated by ged. DO NOT EDIT.\n\npackage main\n")
+	for name := range t.helpers {
+		for _, pkg := range helperImports[name] {
+			t.imports[pkg] = true
+		}
+	}
+	if len(t.imports) > 0 {
+		b.WriteString("\nimport (\n")
+		for _, pkg := range slices.Sorted(maps.Keys(t.imports)) {
+			fmt.Fprintf(&b, "%q\n", pkg)
+		}
+		b.WriteString(")\n")
+	}
+	if len(t.vars) > 0 {
+		b.WriteString("\nvar (\n" + strings.Join(t.vars, "\n") + "\n)\n")
+	}
+	b.WriteString("\nfunc main() {\n" + strings.Join(t.main, "\n") + "\n}\n")
+	for _, fn := range t.funcs {
+		b.WriteString("\n" + fn + "\n")
+	}
+	for _, name := range slices.Sorted(maps.Keys(t.helpers)) {
+		b.WriteString("\n" + goHelpers[name] + "\n")
+	}
+	return b.String()
+}
+
+func (t *transpiler) helper(name string) {
+	t.helpers[name] = true
+	for _, need := range helperNeeds[name] {
+		t.helper(need)
+	}
+}
+
+// goName returns an unused Go identifier for the Ged name, replacing any
+// character Go does not allow in a name, such as a prime, with _.
+func (t *transpiler) goName(name string) string {
+	base := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	candidate := base
+	for n := 2; t.used[candidate] || token.IsKeyword(candidate) || types.Universe.Lookup(candidate) != nil; n++ {
+		candidate = fmt.Sprintf("%s_%d", base, n)
+	}
+	t.used[candidate] = true
+	return candidate
+}
+
+func (t *transpiler) statement(st Statement) error {
+	switch s := st.(type) {
+	case *LetStatement:
+		code, typ, err := t.value(s.Value, s.Token)
+		if err != nil {
+			return err
+		}
+		return t.setGlobal(s.Token, s.Name, code, typ)
+	case *AssignStatement:
+		if b, ok := t.globals[s.Name]; !ok || b.fn != nil {
+			return compileErrorf(s.Token, "assignment to undefined variable %s", s.Name)
+		}
+		code, typ, err := t.value(s.Value, s.Token)
+		if err != nil {
+			return err
+		}
+		return t.setGlobal(s.Token, s.Name, code, typ)
+	case *FunctionDef:
+		if b, ok := t.globals[s.Name]; ok && (b.fn != nil || t.captured[s.Name]) {
+			return compileErrorf(s.Token, "cannot transpile redefining %s", s.Name)
+		}
+		t.globals[s.Name] = &goBinding{fn: s}
+	case *ExpressionStatement:
+		code, typ, err := t.expr(s.Expr)
+		if err != nil {
+			return err
+		}
+		if typ != goNone {
+			code = "_ = " + code
+		}
+		t.main = append(t.main, code)
+	default:
+		return fmt.Errorf("cannot transpile %T", st)
+	}
+	return nil
+}
+
+// value translates e, which is bound by a let or assignment at tok.
+func (t *transpiler) value(e Expr, tok Token) (string, goType, error) {
+	code, typ, err := t.expr(e)
+	if err == nil && typ == goNone {
+		return "", typ, compileErrorf(tok, "cannot transpile binding %s, which has no value", e)
+	}
+	return code, typ, err
+}
+
+// setGlobal assigns code to the top-level name. As top-level code runs
+// straight through, a name that changes type can simply move to another Go
+// variable, unless a function has read the old one.
+func (t *transpiler) setGlobal(tok Token, name, code string, typ goType) error {
+	if name == "_" {
+		t.main = append(t.main, "_ = "+code)
+		return nil
+	}
+	b, ok := t.globals[name]
+	if ok && b.fn != nil {
+		return compileErrorf(tok, "cannot transpile redefining %s", name)
+	}
+	if !ok || b.typ != typ {
+		if ok && t.captured[name] {
+			return compileErrorf(tok, "cannot transpile %s changing from %s to %s after a function uses it", name, b.typ, typ)
+		}
+		b = &goBinding{name: t.goName(name), typ: typ}
+		t.vars = append(t.vars, b.name+" "+string(typ))
+		t.globals[name] = b
+	}
+	t.main = append(t.main, b.name+" = "+code)
+	return nil
+}
+
+func (t *transpiler) lookup(name string) (*goBinding, bool) {
+	for i := len(t.scopes) - 1; i >= 0; i-- {
+		if b, ok := t.scopes[i][name]; ok {
+			return b, true
+		}
+	}
+	b, ok := t.globals[name]
+	if ok && t.functions > 0 && b.fn == nil {
+		t.captured[name] = true
+	}
+	return b, ok
+}
+
+func (t *transpiler) expr(e Expr) (string, goType, error) {
+	switch n := e.(type) {
+	case *NumberLiteral:
+		v, err := numberValue(n.Token)
+		if err != nil {
+			return "", goNone, err
+		}
+		switch v := v.(type) {
+		case IntValue:
+			return goLiteral(strconv.FormatInt(int64(v), 10)), goInt, nil
+		case FloatValue:
+			f := float64(v)
+			if math.IsInf(f, 0) || math.IsNaN(f) {
+				return "", goNone, compileErrorf(n.Token, "cannot transpile %s, which has no Go literal", n.Token.Value)
+			}
+			s := strconv.FormatFloat(f, 'g', -1, 64)
+			if !strings.ContainsAny(s, ".e") {
+				s += ".0"
+			}
+			return goLiteral(s), goFloat, nil
+		}
+	case *StringLiteral:
+		return strconv.Quote(n.Token.Value), goString, nil
+	case *CharacterLiteral:
+		return strconv.Quote(n.Token.Value), goString, nil
+	case *BoolLiteral:
+		b, ok := n.Token.Parsed.(bool)
+		if !ok {
+			b = n.Token.Value == "true"
+		}
+		return strconv.FormatBool(b), goBool, nil
+	case *InterpStringExpr:
+		return t.interp(n)
+	case *Ident:
+		b, ok := t.lookup(n.Name)
+		if !ok {
+			if n.Name == "println" || n.Name == "printf" {
+				return "", goNone, compileErrorf(n.Token, "cannot transpile %s other than in a call", n.Name)
+			}
+			return "", goNone, compileErrorf(n.Token, "undefined variable %s", n.Name)
+		}
+		if b.fn != nil {
+			return "", goNone, compileErrorf(n.Token, "cannot transpile %s other than in a call", n.Name)
+		}
+		return b.name, b.typ, nil
+	case *UnaryExpr:
+		code, typ, err := t.expr(n.Operand)
+		if err != nil {
+			return "", goNone, err
+		}
+		if n.Op.Type == Minus && typ.isNumber() || n.Op.Type == Bang && typ == goBool {
+			return "(" + n.Op.Value + code + ")", typ, nil
+		}
+		return "", goNone, compileErrorf(n.Op, "unsupported operand type for %s: %s", n.Op.Value, typ)
+	case *BinaryExpr:
+		return t.binary(n)
+	case *CallExpr:
+		return t.call(n)
+	case *IfExpr:
+		return t.ifExpr(n)
+	case *LetInExpr:
+		return t.bindLocals(n.Token, []string{n.Name}, []Expr{n.Value}, n.Body)
+	case *WhereExpr:
+		return t.bindLocals(n.Token, n.Names, n.Values, n.Expr)
+	}
+	return "", goNone, fmt.Errorf("cannot transpile %T", e)
+}
+
+// goLiteral parenthesizes a negative number, so that x - -1 cannot come out
+// as x--1.
+func goLiteral(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (t *transpiler) interp(n *InterpStringExpr) (string, goType, error) {
+	var parts []string
+	for i, part := range n.Parts {
+		if i%2 == 0 {
+			if s := part.(*StringLiteral).Token.Value; s != "" {
+				parts = append(parts, strconv.Quote(s))
+			}
+			continue
+		}
+		code, typ, err := t.expr(part)
+		if err != nil {
+			return "", goNone, err
+		}
+		switch typ {
+		case goNone:
+			return "", goNone, compileErrorf(n.Token, "interpolated expression %s has no value", part)
+		case goInt:
+			code = "strconv.FormatInt(" + code + ", 10)"
+		case goFloat:
+			code = "strconv.FormatFloat(" + code + ", 'g', -1, 64)"
+		case goBool:
+			code = "strconv.FormatBool(" + code + ")"
+		}
+		if typ != goString {
+			t.imports["strconv"] = true
+		}
+		parts = append(parts, code)
+	}
+	if len(parts) == 0 {
+		return `""`, goString, nil
+	}
+	return "(" + strings.Join(parts, " + ") + ")", goString, nil
+}
+
+// toFloat converts code of numeric type typ to a float64.
+func toFloat(code string, typ goType) string {
+	if typ == goInt {
+		return "float64(" + code + ")"
+	}
+	return code
+}
+
+func (t *transpiler) binary(n *BinaryExpr) (string, goType, error) {
+	_, lnum := n.Left.(*NumberLiteral)
+	_, rnum := n.Right.(*NumberLiteral)
+	if lnum && rnum {
+		// left by Fold because the result has no literal form, and Go
+		// would reject it as a constant expression
+		return "", goNone, compileErrorf(n.Op, "cannot transpile %s, which has no Go literal", n)
+	}
+	l, lt, err := t.expr(n.Left)
+	if err != nil {
+		return "", goNone, err
+	}
+	r, rt, err := t.expr(n.Right)
+	if err != nil {
+		return "", goNone, err
+	}
+	unsupported := compileErrorf(n.Op, "unsupported operand types for %s: %s and %s", n.Op.Value, lt, rt)
+	numbers := lt.isNumber() && rt.isNumber()
+	if numbers && lt != rt {
+		l, r = toFloat(l, lt), toFloat(r, rt)
+		lt, rt = goFloat, goFloat
+	}
+	op := n.Op.Value
+	switch n.Op.Type {
+	case And, Or:
+		if lt != goBool || rt != goBool {
+			return "", goNone, unsupported
+		}
+		op = map[TokenType]string{And: "&&", Or: "||"}[n.Op.Type]
+		return "(" + l + " " + op + " " + r + ")", goBool, nil
+	case Equal, NotEqual, Less, LessEqual, Greater, GreaterEqual:
+		ordered := n.Op.Type != Equal && n.Op.Type != NotEqual
+		if lt != rt || lt == goNone || ordered && lt == goBool {
+			return "", goNone, unsupported
+		}
+		return "(" + l + " " + op + " " + r + ")", goBool, nil
+	case Plus:
+		if lt == goString && rt == goString {
+			return "(" + l + " + " + r + ")", goString, nil
+		}
+		fallthrough
+	case Minus, Asterisk:
+		if !numbers {
+			return "", goNone, unsupported
+		}
+		return "(" + l + " " + op + " " + r + ")", lt, nil
+	case Slash:
+		if !numbers {
+			return "", goNone, unsupported
+		}
+		t.helper("divide")
+		return fmt.Sprintf("divide(%s, %s, %d, %d)", toFloat(l, lt), toFloat(r, rt), n.Op.Line, n.Op.Column), goFloat, nil
+	case Percent:
+		if lt != goInt || rt != goInt {
+			return "", goNone, unsupported
+		}
+		t.helper("modulo")
+		return fmt.Sprintf("modulo(%s, %s, %d, %d)", l, r, n.Op.Line, n.Op.Column), goInt, nil
+	case Power:
+		if !numbers {
+			return "", goNone, unsupported
+		}
+		if lt == goFloat {
+			t.imports["math"] = true
+			return "math.Pow(" + l + ", " + r + ")", goFloat, nil
+		}
+		// a negative exponent gives a float, so only a constant one is known
+		// to keep the result an int
+		if lit, ok := n.Right.(*NumberLiteral); !ok || strings.HasPrefix(lit.Token.Value, "-") {
+			return "", goNone, compileErrorf(n.Op, "cannot transpile %s with an exponent that is not a constant", n)
+		}
+		t.helper("power")
+		return "power(" + l + ", " + r + ")", goInt, nil
+	}
+	return "", goNone, compileErrorf(n.Op, "cannot transpile operator %s", n.Op.Value)
+}
+
+func (t *transpiler) args(exprs []Expr, tok Token) ([]string, []goType, error) {
+	codes := make([]string, len(exprs))
+	types := make([]goType, len(exprs))
+	for i, e := range exprs {
+		code, typ, err := t.expr(e)
+		if err != nil {
+			return nil, nil, err
+		}
+		if typ == goNone {
+			return nil, nil, compileErrorf(tok, "argument %s has no value", e)
+		}
+		codes[i], types[i] = code, typ
+	}
+	return codes, types, nil
+}
+
+func (t *transpiler) call(n *CallExpr) (string, goType, error) {
+	callee, ok := n.Callee.(*Ident)
+	if !ok {
+		return "", goNone, compileErrorf(n.Token, "cannot transpile calling %s", n.Callee)
+	}
+	codes, types, err := t.args(n.Args, n.Token)
+	if err != nil {
+		return "", goNone, err
+	}
+	b, bound := t.lookup(callee.Name)
+	switch {
+	case bound && b.fn != nil:
+		if len(n.Args) != len(b.fn.Params) {
+			return "", goNone, compileErrorf(n.Token, "cannot transpile calling %s with %d arguments instead of %d", callee.Name, len(n.Args), len(b.fn.Params))
+		}
+		inst, err := t.instance(b.fn, types, callee.Token)
+		if err != nil {
+			return "", goNone, err
+		}
+		return inst.name + "(" + strings.Join(codes, ", ") + ")", inst.result, nil
+	case bound:
+		return "", goNone, compileErrorf(n.Token, "cannot transpile calling %s", callee.Name)
+	case callee.Name == "println":
+		t.imports["fmt"] = true
+		return "fmt.Println(" + strings.Join(codes, ", ") + ")", goNone, nil
+	case callee.Name == "printf":
+		return t.printf(n, codes, types)
+	}
+	return "", goNone, compileErrorf(callee.Token, "undefined variable %s", callee.Name)
+}
+
+// printf checks the format against the argument types up front, by
+// formatting zero values of those types the way the built-in would.
+func (t *transpiler) printf(n *CallExpr, codes []string, types []goType) (string, goType, error) {
+	var lit *StringLiteral
+	if len(n.Args) > 0 {
+		lit, _ = n.Args[0].(*StringLiteral)
+	}
+	if lit == nil {
+		return "", goNone, compileErrorf(n.Token, "cannot transpile printf without a literal format")
+	}
+	format := lit.Token.Value
+	zeros := make([]Value, len(types)-1)
+	for i, typ := range types[1:] {
+		zeros[i] = map[goType]Value{goInt: IntValue(0), goFloat: FloatValue(0), goString: StringValue(""), goBool: BoolValue(false)}[typ]
+	}
+	if _, err := sprintf(format, zeros); err != nil {
+		return "", goNone, compileErrorf(n.Token, "%s", err)
+	}
+	// %f accepts an int, which Go's does not
+	arg := 1
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		if format[i] == '%' {
+			continue
+		}
+		if format[i] == 'f' {
+			codes[arg] = toFloat(codes[arg], types[arg])
+		}
+		arg++
+	}
+	t.imports["fmt"] = true
+	return "fmt.Printf(" + strings.Join(codes, ", ") + ")", goNone, nil
+}
+
+// instance returns fn translated for arguments of the given types, doing
+// so the first time. A recursive call met before the result type is known
+// fails with a pendingError; the body is then tried again taking the type
+// of the if branch that did not recurse as the result.
+func (t *transpiler) instance(fn *FunctionDef, types []goType, tok Token) (*goInstance, error) {
+	names := make([]string, len(types))
+	for i, typ := range types {
+		names[i] = string(typ)
+	}
+	key := instanceKey{fn, strings.Join(names, ",")}
+	if inst, ok := t.instances[key]; ok {
+		if !inst.known {
+			return nil, &pendingError{inst: inst, tok: tok, name: fn.Name}
+		}
+		return inst, nil
+	}
+	inst := &goInstance{name: t.goName(fn.Name)}
+	t.instances[key] = inst
+	params := make(map[string]*goBinding, len(fn.Params))
+	decls := make([]string, len(fn.Params))
+	for i, param := range fn.Params {
+		name := "_"
+		if param != "_" {
+			name = t.goName(param)
+			params[param] = &goBinding{name: name, typ: types[i]}
+		}
+		decls[i] = name + " " + string(types[i])
+	}
+	scopes := t.scopes
+	t.scopes = []map[string]*goBinding{params}
+	t.functions++
+	defer func() {
+		t.scopes = scopes
+		t.functions--
+	}()
+	for {
+		body, typ, err := t.expr(fn.Body)
+		var pending *pendingError
+		if errors.As(err, &pending) && pending.inst == inst && pending.hinted && !inst.known {
+			inst.result, inst.known = pending.hint, true
+			continue
+		}
+		if err == nil && inst.known && typ != inst.result {
+			err = compileErrorf(fn.Token, "cannot infer the result type of %s", fn.Name)
+		}
+		if err != nil {
+			// nothing emitted refers to the names, so they can be reused
+			delete(t.instances, key)
+			delete(t.used, inst.name)
+			for _, b := range params {
+				delete(t.used, b.name)
+			}
+			return nil, err
+		}
+		inst.result, inst.known = typ, true
+		if typ == goNone {
+			t.funcs = append(t.funcs, fmt.Sprintf("func %s(%s) {\n%s\n}", inst.name, strings.Join(decls, ", "), body))
+		} else {
+			t.funcs = append(t.funcs, fmt.Sprintf("func %s(%s) %s {\nreturn %s\n}", inst.name, strings.Join(decls, ", "), string(typ), body))
+		}
+		return inst, nil
+	}
+}
+
+func (t *transpiler) ifExpr(n *IfExpr) (string, goType, error) {
+	cond, ct, err := t.expr(n.Cond)
+	if err != nil {
+		return "", goNone, err
+	}
+	if ct != goBool {
+		return "", goNone, compileErrorf(n.Token, "if condition must be bool, got %s", ct)
+	}
+	then, tt, thenErr := t.expr(n.Then)
+	els, et, elseErr := t.expr(n.Else)
+	var pending *pendingError
+	switch {
+	case thenErr == nil && errors.As(elseErr, &pending) && !pending.hinted:
+		pending.hint, pending.hinted = tt, true
+	case elseErr == nil && errors.As(thenErr, &pending) && !pending.hinted:
+		pending.hint, pending.hinted = et, true
+	}
+	if err := cmp.Or(thenErr, elseErr); err != nil {
+		return "", goNone, err
+	}
+	if tt != et {
+		return "", goNone, compileErrorf(n.Token, "cannot transpile if with branches of types %s and %s", tt, et)
+	}
+	if tt == goNone {
+		return fmt.Sprintf("func() {\nif %s {\n%s\n} else {\n%s\n}\n}()", cond, then, els), goNone, nil
+	}
+	return fmt.Sprintf("func() %s {\nif %s {\nreturn %s\n}\nreturn %s\n}()", string(tt), cond, then, els), tt, nil
+}
+
+// bindLocals translates body with each of names bound in turn to the
+// matching value, all in a scope of their own.
+func (t *transpiler) bindLocals(tok Token, names []string, values []Expr, body Expr) (string, goType, error) {
+	scope := make(map[string]*goBinding, len(names))
+	t.scopes = append(t.scopes, scope)
+	defer func() { t.scopes = t.scopes[:len(t.scopes)-1] }()
+	var lines []string
+	for i, name := range names {
+		code, typ, err := t.value(values[i], tok)
+		if err != nil {
+			return "", goNone, err
+		}
+		if name == "_" {
+			lines = append(lines, "_ = "+code)
+			continue
+		}
+		b := &goBinding{name: t.goName(name), typ: typ}
+		lines = append(lines, "var "+b.name+" "+string(typ)+" = "+code, "_ = "+b.name)
+		scope[name] = b
+	}
+	code, typ, err := t.expr(body)
+	if err != nil {
+		return "", goNone, err
+	}
+	if typ == goNone {
+		return fmt.Sprintf("func() {\n%s\n%s\n}()", strings.Join(lines, "\n"), code), goNone, nil
+	}
+	return fmt.Sprintf("func() %s {\n%s\nreturn %s\n}()", string(typ), strings.Join(lines, "\n"), code), typ, nil
+}
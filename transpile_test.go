@@ -0,0 +1,109 @@
+package ged
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTranspileToGoMatchesInterpreter builds and runs each transpiled
+// program with the go tool, and compares what it prints, and any runtime
+// error, with running the program in the interpreter.
+func TestTranspileToGoMatchesInterpreter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds Go programs")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no go tool:", err)
+	}
+	programs := map[string]string{
+		"sample": `
+			println (420 + 69);
+			let sayHello name = printf "Hi, %s!\n" name;
+			sayHello "world";
+		`,
+		"arithmetic": `let x = 5; let y = x * 2.5;
+			println x y (x / 2) (7 % 3) (2 ** 10) (2.0 ** 0.5) (0.1 + 0.2) 1e21;
+			let big = 9223372036854775807; println (big + 1) (-big) (3 - -1);`,
+		"strings": `let s = "a"; s += "b"; println "${s} ${1 + 2} ${2.5} ${s == "ab"}";
+			printf "%d %f %s %%\n" 3 4 "x"; println ('c' + s) ('\n' == "\n");`,
+		"functions": `let fact n = if n == 0 then 1 else n * fact (n - 1);
+			let even n = if n == 0 then true else odd (n - 1);
+			let odd n = if n == 0 then false else even (n - 1);
+			let twice x = x * 2;
+			println (fact 20) (even 10) (odd 7) (twice 3) (twice 1.5);
+			println (let a = 2 in a * 3) (b + c where b = 1, c = b * 2);`,
+		"retyped": `let x = 1; println x; x = "one"; println x;`,
+		// names Go predeclares, imports or uses in the helpers
+		"go names": `let int = 4; let string = 2.5; let len = 1; let fmt = "f"; let os = 0;
+			let main = 3; let init = 5; let fail = 6; let divide x = x + 1;
+			println (int / 2) (string * 2) len fmt (os % 2) main init fail (divide 1) (int ** 2);
+			let float64 nil = nil + 1; println (float64 2);`,
+		"division by zero": `let zero = 0; println 1; println (1 / zero);`,
+		"modulo by zero":   `let zero = 0; println (1 % zero);`,
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module transpiled\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range programs {
+		program, err := NewParser(NewLexer(src)).ParseProgram()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		goSrc, err := TranspileToGo(program)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(goSrc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command("go", "run", ".")
+		cmd.Dir = dir
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		runErr := cmd.Run()
+
+		var want bytes.Buffer
+		interp := NewInterpreter()
+		interp.Out = &want
+		_, evalErr := interp.Run(program)
+		if stdout.String() != want.String() {
+			t.Errorf("%s: Go output\n%s\ninterpreter output\n%s\nGo source\n%s", name, &stdout, &want, goSrc)
+		}
+		switch {
+		case evalErr == nil && runErr != nil:
+			t.Errorf("%s: Go program failed: %v\n%s\nGo source\n%s", name, runErr, &stderr, goSrc)
+		case evalErr != nil && !strings.Contains(stderr.String(), evalErr.Error()):
+			t.Errorf("%s: Go program reported %q, want %q", name, &stderr, evalErr)
+		}
+	}
+}
+
+func TestTranspileToGoRejects(t *testing.T) {
+	for _, src := range []string{
+		`let f x = x; println f;`,
+		`let xs = [1];`,
+		`let s = "%d"; printf s 1;`,
+		`printf "%d" 1.5;`,
+		`let f x = f x; f 1;`,
+		`let n = 1; let f x = x + n; f 1; n = "s";`,
+		`println (if true then 1 else 1.5);`,
+		`let x = println 1;`,
+		`let y = 1; println (2 ** y);`,
+		`println (2 ** x) where x = 1;`,
+		`println (1e308 * 10);`,
+	} {
+		program, err := NewParser(NewLexer(src)).ParseProgram()
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if goSrc, err := TranspileToGo(program); err == nil {
+			t.Errorf("TranspileToGo(%s) succeeded:\n%s", src, goSrc)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ged
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const stackSize = 2048
+
+// opTokens gives each arithmetic opcode the operator token the evaluator's
+// arithmetic expects, so both share the same int and float rules. The VM
+// places the token at the instruction's source position, so errors report
+// where the operator was.
+var opTokens = map[Opcode]Token{
+	OpAdd: {Value: "+", Type: Plus},
+	OpSub: {Value: "-", Type: Minus},
+	OpMul: {Value: "*", Type: Asterisk},
+	OpDiv: {Value: "/", Type: Slash},
+	OpNeg: {Value: "-", Type: Minus},
+}
+
+// VM is a stack machine that runs compiled Bytecode.
+type VM struct {
+	code    *Bytecode
+	stack   []Value
+	sp      int
+	globals []Value
+}
+
+func NewVM(code *Bytecode) *VM {
+	return &VM{
+		code:    code,
+		stack:   make([]Value, stackSize),
+		globals: make([]Value, len(code.Globals)),
+	}
+}
+
+// Run executes the bytecode and returns the value of the last expression
+// statement, or nil if the program ends with a binding, matching
+// EvalProgram.
+func (vm *VM) Run() (Value, error) {
+	var result Value
+	ins := vm.code.Instructions
+	for ip := 0; ip < len(ins); ip++ {
+		op := Opcode(ins[ip])
+		var operand int
+		if operandWidth(op) == 2 {
+			operand = int(binary.BigEndian.Uint16(ins[ip+1:]))
+			ip += 2
+		}
+		switch op {
+		case OpConstant:
+			if err := vm.push(vm.code.Constants[operand]); err != nil {
+				return nil, err
+			}
+		case OpAdd, OpSub, OpMul, OpDiv:
+			right, left := vm.pop(), vm.pop()
+			v, err := arithmetic(vm.opToken(op, ip), left, right)
+			if err != nil {
+				return nil, err
+			}
+			vm.push(v)
+		case OpNeg:
+			v, err := unary(vm.opToken(op, ip), vm.pop())
+			if err != nil {
+				return nil, err
+			}
+			vm.push(v)
+		case OpGetGlobal:
+			if err := vm.push(vm.globals[operand]); err != nil {
+				return nil, err
+			}
+		case OpSetGlobal:
+			vm.globals[operand] = vm.pop()
+			result = nil
+		case OpPop:
+			result = vm.pop()
+		default:
+			return nil, fmt.Errorf("unknown opcode %s", op)
+		}
+	}
+	return result, nil
+}
+
+// opToken returns the operator token for the operand-less instruction op at
+// offset ip.
+func (vm *VM) opToken(op Opcode, ip int) Token {
+	tok := opTokens[op]
+	pos := vm.code.position(ip)
+	tok.Line, tok.Column = pos.Line, pos.Column
+	return tok
+}
+
+func (vm *VM) push(v Value) error {
+	if vm.sp == len(vm.stack) {
+		return fmt.Errorf("stack overflow")
+	}
+	vm.stack[vm.sp] = v
+	vm.sp++
+	return nil
+}
+
+func (vm *VM) pop() Value {
+	vm.sp--
+	v := vm.stack[vm.sp]
+	vm.stack[vm.sp] = nil
+	return v
+}
@@ -0,0 +1,113 @@
+package ged
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// runVM compiles src and runs it on the VM.
+func runVM(t *testing.T, src string) (Value, error) {
+	t.Helper()
+	code, err := Compile(parseProgram(t, src))
+	if err != nil {
+		t.Fatalf("%q: %v", src, err)
+	}
+	return NewVM(code).Run()
+}
+
+func TestVMMatchesEval(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want Value
+	}{
+		{"1 + 2 * 3;", IntValue(7)},
+		{"(1 + 2) * 3;", IntValue(9)},
+		{"10 - 4 - 3;", IntValue(3)},
+		{"10 / 4;", FloatValue(2.5)},
+		{"2 * 3.5 - 1;", FloatValue(6)},
+		{"-5 + 3;", IntValue(-2)},
+		{"- -5;", IntValue(5)},
+		{"3 - -2 * 4;", IntValue(11)},
+		{`"s";`, StringValue("s")},
+		{`'s' + "t";`, StringValue("st")},
+		{"let x = 5; let y = x * 2.5; y - x;", FloatValue(7.5)},
+		{"let a = 7; let a = a * a; a;", IntValue(49)},
+		{"let x = 1; x = x + 1; x;", IntValue(2)},
+		{"let x = 1;", nil},
+		{"1; let x = 2;", nil},
+		{`let s = "a"; s + "b";`, StringValue("ab")},
+	} {
+		want, err := runProgram(t, tt.src, NewEnvironment())
+		if err != nil || want != tt.want {
+			t.Errorf("%q: Eval gave %v, %v, want %v", tt.src, want, err, tt.want)
+			continue
+		}
+		if got, err := runVM(t, tt.src); err != nil || got != want {
+			t.Errorf("%q: VM gave %v, %v, Eval gave %v", tt.src, got, err, want)
+		}
+	}
+}
+
+func TestVMErrorsMatchEval(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"1 / 0;", "runtime error at line 1, col 3: division by zero"},
+		{"let x = 1;\n1 + 2 / 0;", "runtime error at line 2, col 7: division by zero"},
+		{"let x = 4;\nlet y = 0;\nx + 1\n  / y;", "runtime error at line 4, col 3: division by zero"},
+		{"let x = 0; 2.5 / x;", "runtime error at line 1, col 16: division by zero"},
+		{`"a" - 1;`, "runtime error at line 1, col 5: unsupported operand types for -: string and int"},
+		{`-"a";`, "runtime error at line 1, col 1: unsupported operand type for -: string"},
+		{"let s = \"a\";\n-s;", "runtime error at line 2, col 1: unsupported operand type for -: string"},
+	} {
+		for name, run := range map[string]func() (Value, error){
+			"Eval": func() (Value, error) { return runProgram(t, tt.src, NewEnvironment()) },
+			"VM":   func() (Value, error) { return runVM(t, tt.src) },
+		} {
+			_, err := run()
+			var runtimeErr *RuntimeError
+			if !errors.As(err, &runtimeErr) || err.Error() != tt.want {
+				t.Errorf("%q: %s gave error %v, want %s", tt.src, name, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestBytecodePositions(t *testing.T) {
+	code, err := Compile(parseProgram(t, "let x = 4;\nlet y = 0;\nx + 1\n  / y;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Count(code.Instructions.String(), "\n")
+	if len(code.Positions) != lines {
+		t.Errorf("got %d positions for %d instructions", len(code.Positions), lines)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"y + 1;", "compile error at line 1, col 1: undefined variable y"},
+		{"let x = 1;\nx = y;", "compile error at line 2, col 5: undefined variable y"},
+		{"z = 1;", "compile error at line 1, col 1: assignment to undefined variable z"},
+	} {
+		_, err := Compile(parseProgram(t, tt.src))
+		var compileErr *CompileError
+		if !errors.As(err, &compileErr) || err.Error() != tt.want {
+			t.Errorf("%q: got error %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	code, err := Compile(parseProgram(t, "let x = 2; x + 1;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "0000 OpConstant 0\n0003 OpSetGlobal 0\n0006 OpGetGlobal 0\n0009 OpConstant 1\n0012 OpAdd\n0013 OpPop\n"
+	if got := code.Instructions.String(); got != want {
+		t.Errorf("disassembled as\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package ged
+
+// Walk calls visit for node and then, if visit returns true, walks each of
+// its children in source order. A nil node is not visited.
+func Walk(node Node, visit func(Node) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *LetStatement:
+		Walk(n.Value, visit)
+	case *FunctionDef:
+		Walk(n.Body, visit)
+	case *AssignStatement:
+		Walk(n.Value, visit)
+	case *ExpressionStatement:
+		Walk(n.Expr, visit)
+	case *InterpStringExpr:
+		walkExprs(n.Parts, visit)
+	case *RecordLiteral:
+		walkExprs(n.Values, visit)
+	case *LetInExpr:
+		Walk(n.Value, visit)
+		Walk(n.Body, visit)
+	case *WhereExpr:
+		Walk(n.Expr, visit)
+		walkExprs(n.Values, visit)
+	case *ListExpr:
+		walkExprs(n.Elements, visit)
+	case *IndexExpr:
+		Walk(n.Object, visit)
+		Walk(n.Index, visit)
+	case *BlockExpr:
+		for _, st := range n.Statements {
+			Walk(st, visit)
+		}
+		Walk(n.Result, visit)
+	case *MemberExpr:
+		Walk(n.Object, visit)
+	case *UnaryExpr:
+		Walk(n.Operand, visit)
+	case *BinaryExpr:
+		Walk(n.Left, visit)
+		Walk(n.Right, visit)
+	case *CallExpr:
+		if n.Token.Type == Pipe {
+			// x |> f a is the call f a x, but x comes first in the source
+			last := len(n.Args) - 1
+			Walk(n.Args[last], visit)
+			Walk(n.Callee, visit)
+			walkExprs(n.Args[:last], visit)
+			break
+		}
+		Walk(n.Callee, visit)
+		walkExprs(n.Args, visit)
+	case *IfExpr:
+		Walk(n.Cond, visit)
+		Walk(n.Then, visit)
+		Walk(n.Else, visit)
+	}
+}
+
+func walkExprs(exprs []Expr, visit func(Node) bool) {
+	for _, e := range exprs {
+		Walk(e, visit)
+	}
+}
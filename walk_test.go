@@ -0,0 +1,56 @@
+package ged
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	program := parseProgram(t, `let f x = x + 1; let y = [1, 2.5, {a: 3}.a][0]; println "${f 4}"; y = y where z = 6;
+{ let q = 7; if true then 8 else -9 } |> f;`)
+	var numbers []string
+	for _, st := range program.Statements {
+		Walk(st, func(n Node) bool {
+			if lit, ok := n.(*NumberLiteral); ok {
+				numbers = append(numbers, lit.Token.Value)
+			}
+			return true
+		})
+	}
+	if want := []string{"1", "1", "2.5", "3", "0", "4", "6", "7", "8", "9"}; !slices.Equal(numbers, want) {
+		t.Errorf("visited numbers %q, want %q", numbers, want)
+	}
+
+	// returning false skips the children of a node but not its siblings
+	var visited []string
+	Walk(program.Statements[1], func(n Node) bool {
+		visited = append(visited, n.String())
+		_, isList := n.(*ListExpr)
+		return !isList
+	})
+	if want := []string{"let y = ([1, 2.5, ({a: 3}.a)][0]);", "([1, 2.5, ({a: 3}.a)][0])", "[1, 2.5, ({a: 3}.a)]", "0"}; !slices.Equal(visited, want) {
+		t.Errorf("visited %q, want %q", visited, want)
+	}
+
+	Walk(nil, func(Node) bool {
+		t.Error("visited a nil node")
+		return true
+	})
+}
+
+func TestWalkVisitsPipeCallsInSourceOrder(t *testing.T) {
+	program, err := NewParser(NewLexer("x |> f a |> g;")).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	Walk(program.Statements[0], func(n Node) bool {
+		if ident, ok := n.(*Ident); ok {
+			names = append(names, ident.Name)
+		}
+		return true
+	})
+	if want := []string{"x", "f", "a", "g"}; !slices.Equal(names, want) {
+		t.Errorf("visited %v, want %v", names, want)
+	}
+}